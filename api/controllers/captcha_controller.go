@@ -48,7 +48,8 @@ func (a CaptchaController) VerifyCaptcha(ctx echo.Context) error {
 		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
 	}
 
-	ok := a.captcha.Verify(verify.ID, verify.Code, false)
+	// a right answer is spent by the request it is submitted with
+	ok := a.captcha.Check(verify.ID, verify.Code)
 	if !ok {
 		return echox.Response{Code: http.StatusBadRequest, Message: errors.CaptchaAnswerCodeNoMatch}.JSON(ctx)
 	}
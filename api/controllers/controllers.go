@@ -1,6 +1,10 @@
 package controllers
 
-import "go.uber.org/fx"
+import (
+	"github.com/RealLiuSha/echo-admin/models/dto"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/fx"
+)
 
 // Module exported for initializing application
 var Module = fx.Options(
@@ -9,4 +13,15 @@ var Module = fx.Options(
 	fx.Provide(NewUserController),
 	fx.Provide(NewRoleController),
 	fx.Provide(NewMenuController),
+	fx.Provide(NewLoginLogController),
+	fx.Provide(NewDepartmentController),
+	fx.Provide(NewPermissionController),
 )
+
+// clientOf describes the client of the request
+func clientOf(ctx echo.Context) dto.Client {
+	return dto.Client{
+		IP:        ctx.RealIP(),
+		UserAgent: ctx.Request().UserAgent(),
+	}
+}
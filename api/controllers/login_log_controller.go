@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/RealLiuSha/echo-admin/api/services"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/pkg/echox"
+	"github.com/labstack/echo/v4"
+)
+
+type LoginLogController struct {
+	loginLogService services.LoginLogService
+	logger          lib.Logger
+}
+
+// NewLoginLogController creates new login log controller
+func NewLoginLogController(
+	loginLogService services.LoginLogService,
+	logger lib.Logger,
+) LoginLogController {
+	return LoginLogController{
+		loginLogService: loginLogService,
+		logger:          logger,
+	}
+}
+
+// @tags LoginLog
+// @summary LoginLog Query
+// @produce application/json
+// @param data query models.LoginLogQueryParam true "LoginLogQueryParam"
+// @success 200 {object} echox.Response{data=models.LoginLogQueryResult} "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 500 {object} echox.Response "internal error"
+// @router /api/login-logs [get]
+func (a LoginLogController) Query(ctx echo.Context) error {
+	param := new(models.LoginLogQueryParam)
+	if err := ctx.Bind(param); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	qr, err := a.loginLogService.Query(param)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: qr}.JSON(ctx)
+}
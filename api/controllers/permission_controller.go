@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/RealLiuSha/echo-admin/api/services"
+	"github.com/RealLiuSha/echo-admin/constants"
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+	"github.com/RealLiuSha/echo-admin/pkg/echox"
+	"github.com/labstack/echo/v4"
+
+	"gorm.io/gorm"
+)
+
+type PermissionController struct {
+	permissionService services.PermissionService
+	logger            lib.Logger
+}
+
+// NewPermissionController creates new permission controller
+func NewPermissionController(
+	permissionService services.PermissionService,
+	logger lib.Logger,
+) PermissionController {
+	return PermissionController{
+		permissionService: permissionService,
+		logger:            logger,
+	}
+}
+
+// @tags Permission
+// @summary Permission Check
+// @produce application/json
+// @param data query models.PermissionCheckParam true "PermissionCheckParam"
+// @success 200 {object} echox.Response{data=models.PermissionCheckResult} "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 500 {object} echox.Response "internal error"
+// @router /api/permissions/check [get]
+func (a PermissionController) Check(ctx echo.Context) error {
+	param := new(models.PermissionCheckParam)
+	if err := ctx.Bind(param); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	a.defaultUser(ctx, param)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	result, err := a.permissionService.WithTrx(trxHandle).Check(param)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: result}.JSON(ctx)
+}
+
+// @tags Permission
+// @summary Permission Bulk Check
+// @produce application/json
+// @param data body models.PermissionCheckParams true "PermissionCheckParams"
+// @success 200 {object} echox.Response{data=models.PermissionCheckResults} "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 500 {object} echox.Response "internal error"
+// @router /api/permissions/check [post]
+func (a PermissionController) CheckAll(ctx echo.Context) error {
+	params := make(models.PermissionCheckParams, 0)
+	if err := ctx.Bind(&params); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	for _, param := range params {
+		if param == nil {
+			return echox.Response{Code: http.StatusBadRequest, Message: errors.PermissionInvalidCheck}.JSON(ctx)
+		}
+
+		a.defaultUser(ctx, param)
+	}
+
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	results, err := a.permissionService.WithTrx(trxHandle).CheckAll(params)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: results}.JSON(ctx)
+}
+
+// defaultUser checks the current user when the param names no user
+func (a PermissionController) defaultUser(ctx echo.Context, param *models.PermissionCheckParam) {
+	if param.UserID != "" || param.Username != "" {
+		return
+	}
+
+	if claims, ok := ctx.Get(constants.CurrentUser).(*dto.JwtClaims); ok {
+		param.UserID = claims.ID
+	}
+}
@@ -1,37 +1,68 @@
 package controllers
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/RealLiuSha/echo-admin/api/services"
 	"github.com/RealLiuSha/echo-admin/constants"
 	"github.com/RealLiuSha/echo-admin/errors"
 	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
 	"github.com/RealLiuSha/echo-admin/models/dto"
 	"github.com/RealLiuSha/echo-admin/pkg/echox"
 	"github.com/labstack/echo/v4"
+
+	"gorm.io/gorm"
 )
 
 type PublicController struct {
-	userService services.UserService
-	authService services.AuthService
-	captcha     lib.Captcha
-	logger      lib.Logger
+	userService          services.UserService
+	authService          services.AuthService
+	twoFactorService     services.TwoFactorService
+	loginGuardService    services.LoginGuardService
+	passwordResetService services.PasswordResetService
+	oidcService          services.OIDCService
+	loginLogService      services.LoginLogService
+	permissionService    services.PermissionService
+	config               lib.Config
+	authCookie           lib.AuthCookie
+	captcha              lib.Captcha
+	logger               lib.Logger
 }
 
 // NewPublicController creates new public controller
 func NewPublicController(
 	userService services.UserService,
 	authService services.AuthService,
+	twoFactorService services.TwoFactorService,
+	loginGuardService services.LoginGuardService,
+	passwordResetService services.PasswordResetService,
+	oidcService services.OIDCService,
+	loginLogService services.LoginLogService,
+	permissionService services.PermissionService,
+	config lib.Config,
+	authCookie lib.AuthCookie,
 	captcha lib.Captcha,
 	logger lib.Logger,
 ) PublicController {
 	return PublicController{
-		userService: userService,
-		authService: authService,
-		captcha:     captcha,
-		logger:      logger,
+		userService:          userService,
+		authService:          authService,
+		twoFactorService:     twoFactorService,
+		loginGuardService:    loginGuardService,
+		passwordResetService: passwordResetService,
+		oidcService:          oidcService,
+		loginLogService:      loginLogService,
+		permissionService:    permissionService,
+		config:               config,
+		authCookie:           authCookie,
+		captcha:              captcha,
+		logger:               logger,
 	}
 }
 
@@ -61,6 +92,15 @@ func (a PublicController) SysRoutes(ctx echo.Context) error {
 	return echox.Response{Code: http.StatusOK, Data: routes}.JSON(ctx)
 }
 
+// @Tags Public
+// @Summary JWKS
+// @Produce application/json
+// @Success 200 {object} jwk.Set "ok"
+// @Router /.well-known/jwks.json [get]
+func (a PublicController) JWKS(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, a.authService.JWKS())
+}
+
 // @Tags Public
 // @Summary UserInfo
 // @Produce application/json
@@ -76,6 +116,8 @@ func (a PublicController) UserInfo(ctx echo.Context) error {
 		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
 	}
 
+	userinfo.ImpersonatedBy = claims.ImpersonatorName
+
 	return echox.Response{Code: http.StatusOK, Data: userinfo}.JSON(ctx)
 }
 
@@ -94,14 +136,37 @@ func (a PublicController) MenuTree(ctx echo.Context) error {
 		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
 	}
 
-	return echox.Response{Code: http.StatusOK, Data: menuTrees}.JSON(ctx)
+	permissions, err := a.permissionService.GetUserPermissions(claims.ID)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: menuTrees.FillPermissions(permissions)}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserPermissions
+// @Produce application/json
+// @Success 200 {string} echox.Response{data=models.UserPermissions} "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @failure 500 {string} echox.Response "internal error"
+// @Router /api/publics/user/permissions [get]
+func (a PublicController) UserPermissions(ctx echo.Context) error {
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+
+	permissions, err := a.permissionService.GetUserPermissions(claims.ID)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: permissions}.JSON(ctx)
 }
 
 // @Tags Public
 // @Summary UserLogin
 // @Produce application/json
 // @Param data body dto.Login true "Login"
-// @Success 200 {string} echox.Response "ok"
+// @Success 200 {string} echox.Response{data=dto.TokenPair} "ok"
 // @failure 400 {string} echox.Response "bad request"
 // @failure 500 {string} echox.Response "internal error"
 // @Router /api/publics/user/login [post]
@@ -112,21 +177,580 @@ func (a PublicController) UserLogin(ctx echo.Context) error {
 		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
 	}
 
-	if !a.captcha.Verify(login.CaptchaID, login.CaptchaCode, false) {
-		return echox.Response{Code: http.StatusBadRequest, Message: errors.CaptchaAnswerCodeNoMatch}.JSON(ctx)
+	if retry, err := a.loginGuardService.Check(login.Username, ctx.RealIP()); err != nil {
+		a.recordLogin(ctx, constants.LoginMethodPassword, login.Username, nil, err)
+		if retry > 0 {
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			return echox.Response{Code: http.StatusTooManyRequests, Message: err}.JSON(ctx)
+		}
+
+		return echox.Response{Code: http.StatusInternalServerError, Message: err}.JSON(ctx)
+	}
+
+	if err := a.verifyLoginCaptcha(ctx, login); err != nil {
+		a.recordLogin(ctx, constants.LoginMethodPassword, login.Username, nil, err)
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
 	}
 
 	user, err := a.userService.Verify(login.Username, login.Password)
+	if admin := a.userService.GetSuperAdmin(); admin != nil && admin.Username == login.Username {
+		a.logger.Security("super_admin_login",
+			"username", login.Username,
+			"ip", ctx.RealIP(),
+			"user_agent", ctx.Request().UserAgent(),
+			"success", err == nil,
+		)
+	}
+
 	if err != nil {
+		a.recordLogin(ctx, constants.LoginMethodPassword, login.Username, nil, err)
+		if errors.Is(err, errors.UserInvalidPassword) || errors.Is(err, errors.UserRecordNotFound) {
+			if err := a.loginGuardService.Fail(login.Username, ctx.RealIP()); err != nil {
+				a.logger.Zap.Errorf("Error to record the failed login of %s: %v", login.Username, err)
+			}
+		}
+
 		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
 	}
 
-	token, err := a.authService.GenerateToken(user)
+	// the login is recorded and the failed logins are reset by the second step
+	if user.TwoFactorEnabled {
+		challenge, err := a.twoFactorService.Challenge(user)
+		if err != nil {
+			return echox.Response{Code: http.StatusInternalServerError, Message: err}.JSON(ctx)
+		}
+
+		return echox.Response{Code: http.StatusOK, Data: challenge}.JSON(ctx)
+	}
+
+	if err := a.loginGuardService.Succeed(login.Username); err != nil {
+		a.logger.Zap.Errorf("Error to reset the failed logins of %s: %v", login.Username, err)
+	}
+
+	restrictions, err := a.restrictionsOf(user)
+	if err != nil {
+		return echox.Response{Code: http.StatusInternalServerError, Message: err}.JSON(ctx)
+	}
+
+	token, err := a.authService.GenerateToken(user, clientOf(ctx), restrictions...)
 	if err != nil {
 		return echox.Response{Code: http.StatusInternalServerError, Message: errors.AuthTokenGenerateFail}.JSON(ctx)
 	}
 
-	return echox.Response{Code: http.StatusOK, Data: echo.Map{"token": token}}.JSON(ctx)
+	a.recordLogin(ctx, constants.LoginMethodPassword, login.Username, user, nil)
+	return echox.Response{Code: http.StatusOK, Data: a.issue(ctx, token)}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserLoginTwoFactor
+// @Produce application/json
+// @Param data body dto.LoginTwoFactor true "LoginTwoFactor"
+// @Success 200 {string} echox.Response{data=dto.TokenPair} "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @failure 500 {string} echox.Response "internal error"
+// @Router /api/publics/user/login/2fa [post]
+func (a PublicController) UserLoginTwoFactor(ctx echo.Context) error {
+	login := new(dto.LoginTwoFactor)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+
+	if err := ctx.Bind(login); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	user, err := a.twoFactorService.WithTrx(trxHandle).VerifyChallenge(login.Challenge, login.Code)
+	if err == nil && user.Status != 1 {
+		err = errors.UserIsDisable
+	}
+
+	if err != nil {
+		a.recordLogin(ctx, constants.LoginMethodTwoFactor, "", user, err)
+		if errors.Is(err, errors.TwoFactorCodeInvalid) {
+			if err := a.loginGuardService.Fail(user.Username, ctx.RealIP()); err != nil {
+				a.logger.Zap.Errorf("Error to record the failed login of %s: %v", user.Username, err)
+			}
+		}
+
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	if err := a.loginGuardService.Succeed(user.Username); err != nil {
+		a.logger.Zap.Errorf("Error to reset the failed logins of %s: %v", user.Username, err)
+	}
+
+	restrictions, err := a.restrictionsOf(user)
+	if err != nil {
+		return echox.Response{Code: http.StatusInternalServerError, Message: err}.JSON(ctx)
+	}
+
+	token, err := a.authService.GenerateToken(user, clientOf(ctx), restrictions...)
+	if err != nil {
+		return echox.Response{Code: http.StatusInternalServerError, Message: errors.AuthTokenGenerateFail}.JSON(ctx)
+	}
+
+	a.recordLogin(ctx, constants.LoginMethodTwoFactor, user.Username, user, nil)
+	return echox.Response{Code: http.StatusOK, Data: a.issue(ctx, token)}.JSON(ctx)
+}
+
+// verifyLoginCaptcha spends the captcha of the login, it is asked for after failed logins
+// of the username or ip, and never from trusted networks
+func (a PublicController) verifyLoginCaptcha(ctx echo.Context, login *dto.Login) error {
+	failures, err := a.loginGuardService.Failures(login.Username, ctx.RealIP())
+	if err != nil {
+		// ask for the captcha when the failed logins are unknown
+		a.logger.Zap.Errorf("Error to read the failed logins of %s: %v", login.Username, err)
+		failures = math.MaxInt64
+	}
+
+	if !a.captcha.Required(ctx, failures) {
+		return nil
+	}
+
+	if login.CaptchaID == "" || login.CaptchaCode == "" {
+		return errors.CaptchaRequired
+	}
+
+	if !a.captcha.Verify(login.CaptchaID, login.CaptchaCode, true) {
+		return errors.CaptchaAnswerCodeNoMatch
+	}
+
+	return nil
+}
+
+// recordLogin writes the login log, a failure of the log does not fail the login
+func (a PublicController) recordLogin(ctx echo.Context, method, username string, user *models.User, err error) {
+	if err := a.loginLogService.Record(method, username, user, clientOf(ctx), err); err != nil {
+		a.logger.Zap.Errorf("Error to record the login of %s: %v", username, err)
+	}
+}
+
+// @Tags Public
+// @Summary OIDCLogin
+// @Success 302 {string} string "redirect to the provider"
+// @failure 400 {string} echox.Response "bad request"
+// @failure 500 {string} echox.Response "internal error"
+// @Router /api/publics/oidc/login [get]
+func (a PublicController) OIDCLogin(ctx echo.Context) error {
+	authURL, binding, err := a.oidcService.Login()
+	if err != nil {
+		if errors.Is(err, errors.OIDCNotEnabled) {
+			return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+		}
+
+		return echox.Response{Code: http.StatusInternalServerError, Message: err}.JSON(ctx)
+	}
+
+	a.authCookie.SetOIDCState(ctx, binding)
+
+	return ctx.Redirect(http.StatusFound, authURL)
+}
+
+// @Tags Public
+// @Summary OIDCCallback
+// @Produce application/json
+// @Param state query string true "state"
+// @Param code query string true "code"
+// @Success 200 {string} echox.Response{data=dto.TokenPair} "ok"
+// @Success 302 {string} string "redirect to the success url with the tokens in the fragment"
+// @failure 400 {string} echox.Response "bad request"
+// @failure 500 {string} echox.Response "internal error"
+// @Router /api/publics/oidc/callback [get]
+func (a PublicController) OIDCCallback(ctx echo.Context) error {
+	if reason := ctx.QueryParam("error"); reason != "" {
+		a.logger.Zap.Warnf("oidc login is refused by the provider: %s %s", reason, ctx.QueryParam("error_description"))
+		a.recordLogin(ctx, constants.LoginMethodOIDC, "", nil, fmt.Errorf("refused by the provider: %s", reason))
+		return echox.Response{Code: http.StatusBadRequest, Message: errors.UserNoPermission}.JSON(ctx)
+	}
+
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	binding := a.authCookie.OIDCState(ctx)
+
+	user, err := a.oidcService.WithTrx(trxHandle).Callback(ctx.QueryParam("state"), binding, ctx.QueryParam("code"))
+	if err != nil {
+		a.recordLogin(ctx, constants.LoginMethodOIDC, "", nil, err)
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	fragment := url.Values{}
+	var data interface{}
+
+	if user.TwoFactorEnabled {
+		challenge, err := a.twoFactorService.Challenge(user)
+		if err != nil {
+			return echox.Response{Code: http.StatusInternalServerError, Message: err}.JSON(ctx)
+		}
+
+		data = challenge
+		fragment.Set("type", challenge.Type)
+		fragment.Set("challenge", challenge.Challenge)
+		fragment.Set("expires_in", strconv.Itoa(challenge.ExpiresIn))
+	} else {
+		restrictions, err := a.restrictionsOf(user)
+		if err != nil {
+			return echox.Response{Code: http.StatusInternalServerError, Message: err}.JSON(ctx)
+		}
+
+		token, err := a.authService.GenerateToken(user, clientOf(ctx), restrictions...)
+		if err != nil {
+			return echox.Response{Code: http.StatusInternalServerError, Message: errors.AuthTokenGenerateFail}.JSON(ctx)
+		}
+
+		a.recordLogin(ctx, constants.LoginMethodOIDC, user.Username, user, nil)
+
+		token = a.issue(ctx, token)
+
+		data = token
+		fragment.Set("token_type", token.TokenType)
+		fragment.Set("expires_in", strconv.Itoa(token.ExpiresIn))
+		if token.AccessToken != "" {
+			fragment.Set("token", token.AccessToken)
+			fragment.Set("refresh_token", token.RefreshToken)
+		}
+		for _, restriction := range token.Restrictions {
+			fragment.Add("restrictions", restriction)
+		}
+	}
+
+	// the fragment is not sent to servers, so the tokens stay out of logs and referers
+	if successURL := a.config.OIDC.SuccessURL; successURL != "" {
+		return ctx.Redirect(http.StatusFound, successURL+"#"+fragment.Encode())
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: data}.JSON(ctx)
+}
+
+// issue hands the token pair to the client, in cookie mode the tokens are only set as cookies
+func (a PublicController) issue(ctx echo.Context, token *dto.TokenPair) *dto.TokenPair {
+	if !a.authCookie.Enabled() {
+		return token
+	}
+
+	a.authCookie.SetTokens(ctx, token.AccessToken, token.ExpiresIn, token.RefreshToken)
+
+	pair := *token
+	pair.AccessToken, pair.RefreshToken = "", ""
+	return &pair
+}
+
+// restrictionsOf returns the steps the user has to complete before the session reaches the whole api
+func (a PublicController) restrictionsOf(user *models.User) ([]string, error) {
+	var restrictions []string
+
+	// users whose roles require two-factor authentication may only enroll until it is enabled
+	if !user.TwoFactorEnabled {
+		if required, err := a.twoFactorService.Required(user.ID); err != nil {
+			return nil, err
+		} else if required {
+			restrictions = append(restrictions, constants.SessionRestrictTwoFactor)
+		}
+	}
+
+	if a.userService.PasswordExpired(user) {
+		restrictions = append(restrictions, constants.SessionRestrictPassword)
+	}
+
+	return restrictions, nil
+}
+
+// @Tags Public
+// @Summary UserPassword
+// @Produce application/json
+// @Param data body dto.ChangePassword true "ChangePassword"
+// @Success 200 {string} echox.Response "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @failure 500 {string} echox.Response "internal error"
+// @Router /api/publics/user/password [put]
+func (a PublicController) UserPassword(ctx echo.Context) error {
+	param := new(dto.ChangePassword)
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+
+	if err := ctx.Bind(param); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	err := a.userService.WithTrx(trxHandle).ChangePassword(claims.ID, param.OldPassword, param.NewPassword)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	if err := a.authService.LiftRestriction(claims.SessionID, constants.SessionRestrictPassword); err != nil {
+		return echox.Response{Code: http.StatusInternalServerError, Message: err}.JSON(ctx)
+	}
+
+	// whoever knew the old password is logged out
+	if err := a.authService.DestroyOtherSessions(claims.ID, claims.SessionID); err != nil {
+		return echox.Response{Code: http.StatusInternalServerError, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserForgotPassword
+// @Produce application/json
+// @Param data body dto.ForgotPassword true "ForgotPassword"
+// @Success 200 {string} echox.Response "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @Router /api/publics/user/password/forgot [post]
+func (a PublicController) UserForgotPassword(ctx echo.Context) error {
+	param := new(dto.ForgotPassword)
+	if err := ctx.Bind(param); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	if !a.captcha.Trusted(ctx) && !a.captcha.Verify(param.CaptchaID, param.CaptchaCode, true) {
+		return echox.Response{Code: http.StatusBadRequest, Message: errors.CaptchaAnswerCodeNoMatch}.JSON(ctx)
+	}
+
+	// the response is the same whether the user exists or not
+	if err := a.passwordResetService.Request(param.Username); err != nil {
+		a.logger.Zap.Errorf("Error to send the password reset of %s: %v", param.Username, err)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserResetPassword
+// @Produce application/json
+// @Param data body dto.ResetPassword true "ResetPassword"
+// @Success 200 {string} echox.Response "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @Router /api/publics/user/password/reset [post]
+func (a PublicController) UserResetPassword(ctx echo.Context) error {
+	param := new(dto.ResetPassword)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+
+	if err := ctx.Bind(param); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	if err := a.passwordResetService.WithTrx(trxHandle).Reset(param.Token, param.Password); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserProfile
+// @Produce application/json
+// @Param data body models.UserProfile true "UserProfile"
+// @Success 200 {string} echox.Response "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @failure 500 {string} echox.Response "internal error"
+// @Router /api/publics/user/profile [put]
+func (a PublicController) UserProfile(ctx echo.Context) error {
+	profile := new(models.UserProfile)
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+
+	if err := ctx.Bind(profile); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	if err := a.userService.WithTrx(trxHandle).UpdateProfile(claims.ID, profile); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserTwoFactorEnroll
+// @Produce application/json
+// @Success 200 {string} echox.Response{data=dto.TwoFactorEnrollment} "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @Router /api/publics/user/2fa/enroll [post]
+func (a PublicController) UserTwoFactorEnroll(ctx echo.Context) error {
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+
+	enrollment, err := a.twoFactorService.WithTrx(trxHandle).Enroll(claims.ID)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: enrollment}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserTwoFactorActivate
+// @Produce application/json
+// @Param data body dto.TwoFactorCode true "TwoFactorCode"
+// @Success 200 {string} echox.Response{data=dto.RecoveryCodes} "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @Router /api/publics/user/2fa/activate [post]
+func (a PublicController) UserTwoFactorActivate(ctx echo.Context) error {
+	param := new(dto.TwoFactorCode)
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+
+	if err := ctx.Bind(param); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	codes, err := a.twoFactorService.WithTrx(trxHandle).Activate(claims.ID, param.Code)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	if err := a.authService.LiftRestriction(claims.SessionID, constants.SessionRestrictTwoFactor); err != nil {
+		return echox.Response{Code: http.StatusInternalServerError, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: codes}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserTwoFactorRecoveryCodes
+// @Produce application/json
+// @Param data body dto.TwoFactorCode true "TwoFactorCode"
+// @Success 200 {string} echox.Response{data=dto.RecoveryCodes} "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @Router /api/publics/user/2fa/recovery-codes [post]
+func (a PublicController) UserTwoFactorRecoveryCodes(ctx echo.Context) error {
+	param := new(dto.TwoFactorCode)
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+
+	if err := ctx.Bind(param); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	codes, err := a.twoFactorService.WithTrx(trxHandle).RegenerateRecoveryCodes(claims.ID, param.Code)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: codes}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserTwoFactorDisable
+// @Produce application/json
+// @Param data body dto.TwoFactorCode true "TwoFactorCode"
+// @Success 200 {string} echox.Response "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @Router /api/publics/user/2fa/disable [post]
+func (a PublicController) UserTwoFactorDisable(ctx echo.Context) error {
+	param := new(dto.TwoFactorCode)
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+
+	if err := ctx.Bind(param); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	if err := a.twoFactorService.WithTrx(trxHandle).Disable(claims.ID, param.Code); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserRefresh
+// @Produce application/json
+// @Param data body dto.RefreshToken true "RefreshToken"
+// @Success 200 {string} echox.Response{data=dto.TokenPair} "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @failure 401 {string} echox.Response "unauthorized"
+// @Router /api/publics/user/refresh [post]
+func (a PublicController) UserRefresh(ctx echo.Context) error {
+	refresh := new(dto.RefreshToken)
+	if a.authCookie.Enabled() {
+		refresh.RefreshToken = a.authCookie.RefreshToken(ctx)
+	}
+
+	if refresh.RefreshToken == "" {
+		if err := ctx.Bind(refresh); err != nil {
+			return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+		}
+	}
+
+	token, err := a.authService.RefreshToken(refresh.RefreshToken)
+	if err != nil {
+		if errors.Is(err, errors.AuthRefreshTokenReused) {
+			a.logger.Zap.Warnf("refresh token reuse detected from %s, session revoked", ctx.RealIP())
+		}
+
+		return echox.Response{Code: http.StatusUnauthorized, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: a.issue(ctx, token)}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserSessions
+// @Produce application/json
+// @Success 200 {string} echox.Response{data=dto.Sessions} "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @failure 500 {string} echox.Response "internal error"
+// @Router /api/publics/user/sessions [get]
+func (a PublicController) UserSessions(ctx echo.Context) error {
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+
+	sessions, err := a.authService.GetUserSessions(claims.ID)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == claims.SessionID
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: sessions}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserLoginLogs
+// @Produce application/json
+// @Param data query models.LoginLogQueryParam true "LoginLogQueryParam"
+// @Success 200 {string} echox.Response{data=models.LoginLogQueryResult} "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @failure 500 {string} echox.Response "internal error"
+// @Router /api/publics/user/logins [get]
+func (a PublicController) UserLoginLogs(ctx echo.Context) error {
+	param := new(models.LoginLogQueryParam)
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+
+	if err := ctx.Bind(param); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	// only the logins of the own account
+	param.UserID, param.Username = claims.ID, ""
+
+	qr, err := a.loginLogService.Query(param)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: qr}.JSON(ctx)
+}
+
+// @Tags Public
+// @Summary UserSession Revoke By ID
+// @Produce application/json
+// @Param id path string true "session id"
+// @Success 200 {string} echox.Response "ok"
+// @failure 400 {string} echox.Response "bad request"
+// @failure 404 {string} echox.Response "not found"
+// @Router /api/publics/user/sessions/{id} [delete]
+func (a PublicController) UserDestroySession(ctx echo.Context) error {
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+
+	session, err := a.authService.GetSession(ctx.Param("id"))
+	if err != nil || session.UserID != claims.ID {
+		return echox.Response{Code: http.StatusNotFound, Message: errors.AuthSessionNotFound}.JSON(ctx)
+	}
+
+	if err := a.authService.DestroySession(session.ID); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
 }
 
 // @Tags Public
@@ -135,9 +759,23 @@ func (a PublicController) UserLogin(ctx echo.Context) error {
 // @Success 200 {string} echox.Response "success"
 // @Router /api/publics/user/logout [post]
 func (a PublicController) UserLogout(ctx echo.Context) error {
+	if a.authCookie.Enabled() {
+		a.authCookie.Clear(ctx)
+	}
+
 	claims, ok := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
 	if ok {
-		a.authService.DestroyToken(claims.Username)
+		a.authService.DestroySession(claims.SessionID)
+
+		if claims.ImpersonatorID != "" {
+			a.logger.Security("impersonation_end",
+				"admin_id", claims.ImpersonatorID,
+				"admin", claims.ImpersonatorName,
+				"user_id", claims.ID,
+				"username", claims.Username,
+				"ip", ctx.RealIP(),
+			)
+		}
 	}
 
 	return echox.Response{Code: http.StatusOK}.JSON(ctx)
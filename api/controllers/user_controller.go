@@ -17,15 +17,30 @@ import (
 )
 
 type UserController struct {
-	userService services.UserService
-	logger      lib.Logger
+	userService        services.UserService
+	authService        services.AuthService
+	twoFactorService   services.TwoFactorService
+	loginGuardService  services.LoginGuardService
+	accessTokenService services.AccessTokenService
+	logger             lib.Logger
 }
 
 // NewUserController creates new user controller
-func NewUserController(userService services.UserService, logger lib.Logger) UserController {
+func NewUserController(
+	userService services.UserService,
+	authService services.AuthService,
+	twoFactorService services.TwoFactorService,
+	loginGuardService services.LoginGuardService,
+	accessTokenService services.AccessTokenService,
+	logger lib.Logger,
+) UserController {
 	return UserController{
-		userService: userService,
-		logger:      logger,
+		userService:        userService,
+		authService:        authService,
+		twoFactorService:   twoFactorService,
+		loginGuardService:  loginGuardService,
+		accessTokenService: accessTokenService,
+		logger:             logger,
 	}
 }
 
@@ -46,7 +61,8 @@ func (a UserController) Query(ctx echo.Context) error {
 		param.RoleIDs = strings.Split(v, ",")
 	}
 
-	qr, err := a.userService.Query(param)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	qr, err := a.userService.WithTrx(trxHandle).Query(param)
 	if err != nil {
 		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
 	}
@@ -68,7 +84,7 @@ func (a UserController) Create(ctx echo.Context) error {
 
 	if err := ctx.Bind(user); err != nil {
 		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
-	} else if user.Password == "" {
+	} else if user.Password == "" && !user.ServiceAccount {
 		return echox.Response{Code: http.StatusBadRequest, Message: errors.UserPasswordRequired}.JSON(ctx)
 	}
 
@@ -92,7 +108,8 @@ func (a UserController) Create(ctx echo.Context) error {
 // @failure 500 {object} echox.Response "internal error"
 // @router /api/users/{id} [get]
 func (a UserController) Get(ctx echo.Context) error {
-	user, err := a.userService.Get(ctx.Param("id"))
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	user, err := a.userService.WithTrx(trxHandle).Get(ctx.Param("id"))
 	if err != nil {
 		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
 	}
@@ -152,7 +169,8 @@ func (a UserController) Delete(ctx echo.Context) error {
 // @failure 500 {object} echox.Response "internal error"
 // @router /api/users/{id}/enable [patch]
 func (a UserController) Enable(ctx echo.Context) error {
-	err := a.userService.UpdateStatus(ctx.Param("id"), 1)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	err := a.userService.WithTrx(trxHandle).UpdateStatus(ctx.Param("id"), 1)
 	if err != nil {
 		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
 	}
@@ -169,10 +187,234 @@ func (a UserController) Enable(ctx echo.Context) error {
 // @failure 500 {object} echox.Response "internal error"
 // @router /api/users/{id}/disable [patch]
 func (a UserController) Disable(ctx echo.Context) error {
-	err := a.userService.UpdateStatus(ctx.Param("id"), -1)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	err := a.userService.WithTrx(trxHandle).UpdateStatus(ctx.Param("id"), -1)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
+}
+
+// @tags User
+// @summary User Sessions By ID
+// @produce application/json
+// @param id path int true "user id"
+// @success 200 {object} echox.Response{data=dto.Sessions} "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 500 {object} echox.Response "internal error"
+// @router /api/users/{id}/sessions [get]
+func (a UserController) Sessions(ctx echo.Context) error {
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	if _, err := a.userService.WithTrx(trxHandle).Get(ctx.Param("id")); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	sessions, err := a.authService.GetUserSessions(ctx.Param("id"))
 	if err != nil {
 		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
 	}
 
+	return echox.Response{Code: http.StatusOK, Data: sessions}.JSON(ctx)
+}
+
+// @tags User
+// @summary User Sessions Revoke By ID
+// @produce application/json
+// @param id path int true "user id"
+// @success 200 {object} echox.Response "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 500 {object} echox.Response "internal error"
+// @router /api/users/{id}/sessions [delete]
+func (a UserController) DestroySessions(ctx echo.Context) error {
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	if _, err := a.userService.WithTrx(trxHandle).Get(ctx.Param("id")); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	if err := a.authService.DestroyUserSessions(ctx.Param("id")); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
+}
+
+// @tags User
+// @summary User Session Revoke By ID
+// @produce application/json
+// @param id path int true "user id"
+// @param sid path string true "session id"
+// @success 200 {object} echox.Response "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 404 {object} echox.Response "not found"
+// @router /api/users/{id}/sessions/{sid} [delete]
+func (a UserController) DestroySession(ctx echo.Context) error {
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	if _, err := a.userService.WithTrx(trxHandle).Get(ctx.Param("id")); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	session, err := a.authService.GetSession(ctx.Param("sid"))
+	if err != nil || session.UserID != ctx.Param("id") {
+		return echox.Response{Code: http.StatusNotFound, Message: errors.AuthSessionNotFound}.JSON(ctx)
+	}
+
+	if err := a.authService.DestroySession(session.ID); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
+}
+
+// @tags User
+// @summary User TwoFactor Reset By ID
+// @produce application/json
+// @param id path int true "user id"
+// @success 200 {object} echox.Response "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 500 {object} echox.Response "internal error"
+// @router /api/users/{id}/2fa [delete]
+func (a UserController) ResetTwoFactor(ctx echo.Context) error {
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	if err := a.twoFactorService.WithTrx(trxHandle).Reset(ctx.Param("id")); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
+}
+
+// @tags User
+// @summary User Unlock By ID
+// @produce application/json
+// @param id path int true "user id"
+// @success 200 {object} echox.Response "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 500 {object} echox.Response "internal error"
+// @router /api/users/{id}/unlock [post]
+func (a UserController) Unlock(ctx echo.Context) error {
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	user, err := a.userService.WithTrx(trxHandle).Get(ctx.Param("id"))
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	if err := a.loginGuardService.Unlock(user.Username); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK}.JSON(ctx)
+}
+
+// @tags User
+// @summary User Impersonate By ID
+// @produce application/json
+// @param id path int true "user id"
+// @success 200 {object} echox.Response{data=dto.TokenPair} "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 403 {object} echox.Response "forbidden"
+// @failure 500 {object} echox.Response "internal error"
+// @router /api/users/{id}/impersonate [post]
+func (a UserController) Impersonate(ctx echo.Context) error {
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+	id := ctx.Param("id")
+
+	// neither access tokens nor impersonation sessions can start an impersonation,
+	// and the super admin is never impersonated
+	if claims.AccessTokenID != "" || claims.ImpersonatorID != "" ||
+		claims.ID == id || a.userService.IsSuperAdmin(id) {
+		return echox.Response{Code: http.StatusForbidden, Message: errors.UserNotImpersonable}.JSON(ctx)
+	}
+
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	user, err := a.userService.WithTrx(trxHandle).Get(id)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	} else if user.Status != 1 {
+		return echox.Response{Code: http.StatusBadRequest, Message: errors.UserIsDisable}.JSON(ctx)
+	}
+
+	token, err := a.authService.Impersonate(claims, user, clientOf(ctx))
+	if err != nil {
+		return echox.Response{Code: http.StatusInternalServerError, Message: errors.AuthTokenGenerateFail}.JSON(ctx)
+	}
+
+	a.logger.Security("impersonation_start",
+		"admin_id", claims.ID,
+		"admin", claims.Username,
+		"user_id", user.ID,
+		"username", user.Username,
+		"ip", ctx.RealIP(),
+		"user_agent", ctx.Request().UserAgent(),
+	)
+
+	return echox.Response{Code: http.StatusOK, Data: token}.JSON(ctx)
+}
+
+// @tags User
+// @summary User AccessTokens By ID
+// @produce application/json
+// @param id path int true "user id"
+// @success 200 {object} echox.Response{data=models.AccessTokenQueryResult} "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 500 {object} echox.Response "internal error"
+// @router /api/users/{id}/tokens [get]
+func (a UserController) AccessTokens(ctx echo.Context) error {
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+	qr, err := a.accessTokenService.WithTrx(trxHandle).Query(ctx.Param("id"))
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: qr}.JSON(ctx)
+}
+
+// @tags User
+// @summary User AccessToken Create By ID
+// @produce application/json
+// @param id path int true "user id"
+// @param data body dto.CreateAccessToken true "CreateAccessToken"
+// @success 200 {object} echox.Response{data=dto.AccessTokenSecret} "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 500 {object} echox.Response "internal error"
+// @router /api/users/{id}/tokens [post]
+func (a UserController) CreateAccessToken(ctx echo.Context) error {
+	param := new(dto.CreateAccessToken)
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+
+	if err := ctx.Bind(param); err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+	secret, err := a.accessTokenService.WithTrx(trxHandle).Create(ctx.Param("id"), claims.Username, param)
+	if err != nil {
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
+	return echox.Response{Code: http.StatusOK, Data: secret}.JSON(ctx)
+}
+
+// @tags User
+// @summary User AccessToken Revoke By ID
+// @produce application/json
+// @param id path int true "user id"
+// @param tid path string true "access token id"
+// @success 200 {object} echox.Response "ok"
+// @failure 400 {object} echox.Response "bad request"
+// @failure 404 {object} echox.Response "not found"
+// @router /api/users/{id}/tokens/{tid} [delete]
+func (a UserController) DestroyAccessToken(ctx echo.Context) error {
+	trxHandle := ctx.Get(constants.DBTransaction).(*gorm.DB)
+
+	claims, _ := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+	err := a.accessTokenService.WithTrx(trxHandle).Delete(ctx.Param("id"), ctx.Param("tid"), claims.Username)
+	if err != nil {
+		if errors.Is(err, errors.AccessTokenNotFound) {
+			return echox.Response{Code: http.StatusNotFound, Message: err}.JSON(ctx)
+		}
+
+		return echox.Response{Code: http.StatusBadRequest, Message: err}.JSON(ctx)
+	}
+
 	return echox.Response{Code: http.StatusOK}.JSON(ctx)
 }
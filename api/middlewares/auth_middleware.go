@@ -6,17 +6,41 @@ import (
 
 	"github.com/RealLiuSha/echo-admin/api/services"
 	"github.com/RealLiuSha/echo-admin/constants"
+	"github.com/RealLiuSha/echo-admin/errors"
 	"github.com/RealLiuSha/echo-admin/lib"
 	"github.com/RealLiuSha/echo-admin/pkg/echox"
+	"github.com/RealLiuSha/echo-admin/pkg/slice"
 	"github.com/labstack/echo/v4"
 )
 
+// paths reachable by a restricted session, so that the restrictions can be lifted
+var restrictionPathPrefixes = map[string][]string{
+	constants.SessionRestrictTwoFactor: {"/api/v1/publics/user/2fa"},
+	constants.SessionRestrictPassword:  {"/api/v1/publics/user/password"},
+}
+
+// paths reachable by any restricted session
+var restrictedPathPrefixes = []string{"/api/v1/publics/user/logout"}
+
+// paths of the account itself, access tokens must not manage sessions, tokens or credentials
+var accessTokenDeniedPathPrefixes = []string{"/api/v1/publics/user/"}
+
+// account paths reachable by an impersonation session, the others manage the account of the user
+var impersonationAllowedPaths = []string{
+	"/api/v1/publics/user",
+	"/api/v1/publics/user/menutree",
+	"/api/v1/publics/user/permissions",
+	"/api/v1/publics/user/logout",
+}
+
 // AuthMiddleware middleware for cors
 type AuthMiddleware struct {
-	config      lib.Config
-	handler     lib.HttpHandler
-	logger      lib.Logger
-	authService services.AuthService
+	config             lib.Config
+	handler            lib.HttpHandler
+	logger             lib.Logger
+	authService        services.AuthService
+	accessTokenService services.AccessTokenService
+	authCookie         lib.AuthCookie
 }
 
 // NewCorsMiddleware creates new cors middleware
@@ -25,12 +49,16 @@ func NewAuthMiddleware(
 	handler lib.HttpHandler,
 	logger lib.Logger,
 	authService services.AuthService,
+	accessTokenService services.AccessTokenService,
+	authCookie lib.AuthCookie,
 ) AuthMiddleware {
 	return AuthMiddleware{
-		config:      config,
-		handler:     handler,
-		logger:      logger,
-		authService: authService,
+		config:             config,
+		handler:            handler,
+		logger:             logger,
+		authService:        authService,
+		accessTokenService: accessTokenService,
+		authCookie:         authCookie,
 	}
 }
 
@@ -52,6 +80,25 @@ func (a AuthMiddleware) core() echo.MiddlewareFunc {
 
 			if auth != "" && strings.HasPrefix(auth, prefix) {
 				token = auth[len(prefix):]
+			} else if a.authCookie.Enabled() {
+				// the browser sends the cookie with any request, so changes have to prove they come from the frontend
+				if token = a.authCookie.Token(ctx); token != "" && !isSafeMethod(request.Method) && !a.authCookie.VerifyCSRF(ctx) {
+					return echox.Response{Code: http.StatusForbidden, Message: errors.AuthCSRFTokenInvalid}.JSON(ctx)
+				}
+			}
+
+			if strings.HasPrefix(token, constants.AccessTokenPrefix) {
+				claims, err := a.accessTokenService.Parse(token)
+				if err != nil {
+					return echox.Response{Code: http.StatusUnauthorized, Message: err}.JSON(ctx)
+				}
+
+				if isIgnorePath(request.URL.Path, accessTokenDeniedPathPrefixes...) {
+					return echox.Response{Code: http.StatusForbidden, Message: errors.AuthTokenScopeDenied}.JSON(ctx)
+				}
+
+				ctx.Set(constants.CurrentUser, claims)
+				return next(ctx)
 			}
 
 			claims, err := a.authService.ParseToken(token)
@@ -59,12 +106,46 @@ func (a AuthMiddleware) core() echo.MiddlewareFunc {
 				return echox.Response{Code: http.StatusUnauthorized, Message: err}.JSON(ctx)
 			}
 
+			if !isReachablePath(request.URL.Path, claims.Restrictions) {
+				return echox.Response{Code: http.StatusForbidden, Message: errors.AuthSessionRestricted}.JSON(ctx)
+			}
+
+			if claims.ImpersonatorID != "" {
+				ctx.Response().Header().Set(constants.HeaderImpersonatedBy, claims.ImpersonatorName)
+				a.logger.Security("impersonation_request",
+					"admin_id", claims.ImpersonatorID,
+					"user_id", claims.ID,
+					"method", request.Method,
+					"path", request.URL.Path,
+				)
+
+				if isIgnorePath(request.URL.Path, accessTokenDeniedPathPrefixes...) &&
+					!slice.ContainsString(impersonationAllowedPaths, request.URL.Path) {
+					return echox.Response{Code: http.StatusForbidden, Message: errors.AuthImpersonationDenied}.JSON(ctx)
+				}
+			}
+
 			ctx.Set(constants.CurrentUser, claims)
 			return next(ctx)
 		}
 	}
 }
 
+// isReachablePath tells whether the path is reachable under the restrictions
+func isReachablePath(path string, restrictions []string) bool {
+	if len(restrictions) == 0 || isIgnorePath(path, restrictedPathPrefixes...) {
+		return true
+	}
+
+	for _, restriction := range restrictions {
+		if isIgnorePath(path, restrictionPathPrefixes[restriction]...) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (a AuthMiddleware) Setup() {
 	if !a.config.Auth.Enable {
 		return
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/RealLiuSha/echo-admin/constants"
+	"github.com/RealLiuSha/echo-admin/errors"
 	"github.com/RealLiuSha/echo-admin/models/dto"
 
 	"github.com/RealLiuSha/echo-admin/api/services"
@@ -59,11 +60,47 @@ func (a CasbinMiddleware) core() echo.MiddlewareFunc {
 				return echox.Response{Code: http.StatusForbidden}.JSON(ctx)
 			}
 
+			// access tokens are further limited by their scopes
+			if claims.AccessTokenID != "" && !isScopeAllowed(claims.Scopes, m) {
+				return echox.Response{Code: http.StatusForbidden, Message: errors.AuthTokenScopeDenied}.JSON(ctx)
+			}
+
+			// impersonation still answers with the permissions of the user, but does not change anything
+			if claims.ImpersonatorID != "" && a.isImpersonationReadOnly() && !isSafeMethod(m) {
+				return echox.Response{Code: http.StatusForbidden, Message: errors.AuthImpersonationReadOnly}.JSON(ctx)
+			}
+
 			return next(ctx)
 		}
 	}
 }
 
+// isScopeAllowed tells whether the scopes allow the http method
+func isScopeAllowed(scopes []string, method string) bool {
+	for _, scope := range scopes {
+		switch scope {
+		case constants.AccessTokenScopeWrite:
+			return true
+		case constants.AccessTokenScopeRead:
+			if isSafeMethod(method) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isSafeMethod tells whether the http method only reads
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func (a CasbinMiddleware) isImpersonationReadOnly() bool {
+	conf := a.config.Auth.Impersonation
+	return conf == nil || conf.ReadOnly
+}
+
 func (a CasbinMiddleware) Setup() {
 	if !a.config.Casbin.Enable {
 		return
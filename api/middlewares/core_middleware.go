@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 
@@ -44,7 +45,9 @@ func (a CoreMiddleware) core() echo.MiddlewareFunc {
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			txHandle := a.db.ORM.Begin()
+			// the hooks run after the commit, e.g. to reload the policy once the changes are visible
+			hooks := &lib.TrxHooks{}
+			txHandle := a.db.ORM.WithContext(lib.WithTrxHooks(context.Background(), hooks)).Begin()
 			logger.Info("beginning database transaction")
 
 			defer func() {
@@ -84,6 +87,8 @@ func (a CoreMiddleware) core() echo.MiddlewareFunc {
 				a.logger.DesugarZap.Info("committing transactions")
 				if err := txHandle.Commit().Error; err != nil {
 					logger.Error(fmt.Sprintf("trx commit error: %v", err))
+				} else {
+					hooks.Run()
 				}
 			}
 
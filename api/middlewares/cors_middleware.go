@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"github.com/RealLiuSha/echo-admin/constants"
 	"github.com/RealLiuSha/echo-admin/lib"
 	"github.com/labstack/echo/v4/middleware"
 )
@@ -27,5 +28,6 @@ func (a CorsMiddleware) Setup() {
 		AllowCredentials: true,
 		AllowHeaders:     []string{"*"},
 		AllowMethods:     []string{"*"},
+		ExposeHeaders:    []string{constants.HeaderImpersonatedBy},
 	}))
 }
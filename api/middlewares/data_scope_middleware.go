@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/api/services"
+	"github.com/RealLiuSha/echo-admin/constants"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+	"github.com/labstack/echo/v4"
+)
+
+// DataScopeMiddleware limits the queries of the request transaction to the data scope of the current user
+type DataScopeMiddleware struct {
+	handler lib.HttpHandler
+	logger  lib.Logger
+
+	dataScopeService services.DataScopeService
+}
+
+// NewDataScopeMiddleware creates new data scope middleware
+func NewDataScopeMiddleware(
+	handler lib.HttpHandler,
+	logger lib.Logger,
+	dataScopeService services.DataScopeService,
+) DataScopeMiddleware {
+	return DataScopeMiddleware{
+		handler:          handler,
+		logger:           logger,
+		dataScopeService: dataScopeService,
+	}
+}
+
+func (a DataScopeMiddleware) core() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			claims, ok := ctx.Get(constants.CurrentUser).(*dto.JwtClaims)
+			if !ok {
+				return next(ctx)
+			}
+
+			trxHandle, ok := ctx.Get(constants.DBTransaction).(*gorm.DB)
+			if !ok {
+				return next(ctx)
+			}
+
+			// the scope is only loaded by the queries that apply it
+			scopeCtx := lib.WithDataScope(trxHandle.Statement.Context, func() (*lib.DataScope, error) {
+				return a.dataScopeService.Get(claims.ID)
+			})
+
+			ctx.Set(constants.DBTransaction, trxHandle.WithContext(scopeCtx))
+			return next(ctx)
+		}
+	}
+}
+
+func (a DataScopeMiddleware) Setup() {
+	a.logger.Zap.Info("setting up data scope middleware")
+	a.handler.Engine.Use(a.core())
+}
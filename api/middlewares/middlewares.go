@@ -9,6 +9,7 @@ var Module = fx.Options(
 	fx.Provide(NewZapMiddleware),
 	fx.Provide(NewAuthMiddleware),
 	fx.Provide(NewCasbinMiddleware),
+	fx.Provide(NewDataScopeMiddleware),
 	fx.Provide(NewMiddlewares),
 )
 
@@ -28,6 +29,7 @@ func NewMiddlewares(
 	zapMiddleware ZapMiddleware,
 	authMiddleware AuthMiddleware,
 	casbinMiddleware CasbinMiddleware,
+	dataScopeMiddleware DataScopeMiddleware,
 ) Middlewares {
 	return Middlewares{
 		coreMiddleware,
@@ -35,6 +37,7 @@ func NewMiddlewares(
 		corsMiddleware,
 		authMiddleware,
 		casbinMiddleware,
+		dataScopeMiddleware,
 	}
 }
 
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/models/database"
+)
+
+// AccessTokenRepository database structure
+type AccessTokenRepository struct {
+	db     lib.Database
+	logger lib.Logger
+}
+
+// NewAccessTokenRepository creates a new access token repository
+func NewAccessTokenRepository(db lib.Database, logger lib.Logger) AccessTokenRepository {
+	return AccessTokenRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithTrx enables repository with transaction
+func (a AccessTokenRepository) WithTrx(trxHandle *gorm.DB) AccessTokenRepository {
+	if trxHandle == nil {
+		a.logger.Zap.Error("Transaction Database not found in echo context. ")
+		return a
+	}
+
+	a.db.ORM = trxHandle
+	return a
+}
+
+func (a AccessTokenRepository) Query(param *models.AccessTokenQueryParam) (*models.AccessTokenQueryResult, error) {
+	db := a.db.ORM.Model(models.AccessToken{})
+
+	if v := param.UserID; v != "" {
+		db = db.Where("user_id=?", v)
+	}
+	if v := param.Token; v != "" {
+		db = db.Where("token=?", v)
+	}
+
+	db = db.Order(param.OrderParam.ParseOrder())
+
+	list := make(models.AccessTokens, 0)
+	pagination, err := QueryPagination(db, param.PaginationParam, &list)
+	if err != nil {
+		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
+	}
+
+	qr := &models.AccessTokenQueryResult{
+		Pagination: pagination,
+		List:       list,
+	}
+
+	return qr, nil
+}
+
+func (a AccessTokenRepository) Get(id string) (*models.AccessToken, error) {
+	token := new(models.AccessToken)
+
+	if ok, err := QueryOne(a.db.ORM.Model(token).Where("id=?", id), token); err != nil {
+		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
+	} else if !ok {
+		return nil, errors.DatabaseRecordNotFound
+	}
+
+	return token, nil
+}
+
+func (a AccessTokenRepository) Create(token *models.AccessToken) error {
+	result := a.db.ORM.Model(token).Create(token)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a AccessTokenRepository) Delete(id string) error {
+	token := new(models.AccessToken)
+
+	result := a.db.ORM.Model(token).Where("id=?", id).Delete(token)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a AccessTokenRepository) DeleteByUserID(userID string) error {
+	token := new(models.AccessToken)
+
+	result := a.db.ORM.Model(token).Where("user_id=?", userID).Delete(token)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a AccessTokenRepository) UpdateLastUsed(id string, at database.Datetime) error {
+	token := new(models.AccessToken)
+
+	result := a.db.ORM.Model(token).Where("id=?", id).UpdateColumn("last_used_at", at)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
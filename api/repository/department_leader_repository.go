@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+)
+
+// DepartmentLeaderRepository database structure
+type DepartmentLeaderRepository struct {
+	db     lib.Database
+	logger lib.Logger
+}
+
+// NewDepartmentLeaderRepository creates a new department leader repository
+func NewDepartmentLeaderRepository(db lib.Database, logger lib.Logger) DepartmentLeaderRepository {
+	return DepartmentLeaderRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithTrx enables repository with transaction
+func (a DepartmentLeaderRepository) WithTrx(trxHandle *gorm.DB) DepartmentLeaderRepository {
+	if trxHandle == nil {
+		a.logger.Zap.Error("Transaction Database not found in echo context. ")
+		return a
+	}
+
+	a.db.ORM = trxHandle
+	return a
+}
+
+func (a DepartmentLeaderRepository) Query(param *models.DepartmentLeaderQueryParam) (*models.DepartmentLeaderQueryResult, error) {
+	db := a.db.ORM.Model(&models.DepartmentLeader{})
+
+	if v := param.DepartmentID; v != "" {
+		db = db.Where("department_id=?", v)
+	}
+
+	if v := param.DepartmentIDs; len(v) > 0 {
+		db = db.Where("department_id IN (?)", v)
+	}
+
+	if v := param.UserID; v != "" {
+		db = db.Where("user_id=?", v)
+	}
+
+	db = db.Order(param.OrderParam.ParseOrder())
+
+	list := make(models.DepartmentLeaders, 0)
+	pagination, err := QueryPagination(db, param.PaginationParam, &list)
+	if err != nil {
+		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
+	}
+
+	qr := &models.DepartmentLeaderQueryResult{
+		Pagination: pagination,
+		List:       list,
+	}
+
+	return qr, nil
+}
+
+func (a DepartmentLeaderRepository) Create(leader *models.DepartmentLeader) error {
+	result := a.db.ORM.Model(leader).Create(leader)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a DepartmentLeaderRepository) Delete(id string) error {
+	leader := new(models.DepartmentLeader)
+
+	result := a.db.ORM.Model(leader).Where("id=?", id).Delete(leader)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a DepartmentLeaderRepository) DeleteByDepartmentID(id string) error {
+	leader := new(models.DepartmentLeader)
+
+	result := a.db.ORM.Model(leader).Where("department_id=?", id).Delete(leader)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a DepartmentLeaderRepository) DeleteByUserID(userID string) error {
+	leader := new(models.DepartmentLeader)
+
+	result := a.db.ORM.Model(leader).Where("user_id=?", userID).Delete(leader)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+)
+
+// LoginLogRepository database structure
+type LoginLogRepository struct {
+	db     lib.Database
+	logger lib.Logger
+}
+
+// NewLoginLogRepository creates a new login log repository
+func NewLoginLogRepository(db lib.Database, logger lib.Logger) LoginLogRepository {
+	return LoginLogRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithTrx enables repository with transaction
+func (a LoginLogRepository) WithTrx(trxHandle *gorm.DB) LoginLogRepository {
+	if trxHandle == nil {
+		a.logger.Zap.Error("Transaction Database not found in echo context. ")
+		return a
+	}
+
+	a.db.ORM = trxHandle
+	return a
+}
+
+func (a LoginLogRepository) Query(param *models.LoginLogQueryParam) (*models.LoginLogQueryResult, error) {
+	db := a.db.ORM.Model(models.LoginLog{})
+
+	if v := param.UserID; v != "" {
+		db = db.Where("user_id=?", v)
+	}
+	if v := param.Username; v != "" {
+		db = db.Where("username=?", v)
+	}
+	if v := param.IP; v != "" {
+		db = db.Where("ip=?", v)
+	}
+	if v := param.Method; v != "" {
+		db = db.Where("method=?", v)
+	}
+	if v := param.Status; v != 0 {
+		db = db.Where("success=?", v > 0)
+	}
+	if v := param.StartTime; v != "" {
+		db = db.Where("created_at>=?", v)
+	}
+	if v := param.EndTime; v != "" {
+		db = db.Where("created_at<=?", v)
+	}
+
+	db = db.Order(param.OrderParam.ParseOrder())
+
+	list := make(models.LoginLogs, 0)
+	pagination, err := QueryPagination(db, param.PaginationParam, &list)
+	if err != nil {
+		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
+	}
+
+	qr := &models.LoginLogQueryResult{
+		Pagination: pagination,
+		List:       list,
+	}
+
+	return qr, nil
+}
+
+func (a LoginLogRepository) Create(log *models.LoginLog) error {
+	result := a.db.ORM.Model(log).Create(log)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
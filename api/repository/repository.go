@@ -8,7 +8,17 @@ var Module = fx.Options(
 	fx.Provide(NewUserRoleRepository),
 	fx.Provide(NewRoleRepository),
 	fx.Provide(NewRoleMenuRepository),
+	fx.Provide(NewRoleInheritRepository),
 	fx.Provide(NewMenuRepository),
 	fx.Provide(NewMenuActionRepository),
 	fx.Provide(NewMenuActionResourceRepository),
+	fx.Provide(NewUserRecoveryCodeRepository),
+	fx.Provide(NewUserPasswordHistoryRepository),
+	fx.Provide(NewAccessTokenRepository),
+	fx.Provide(NewUserIdentityRepository),
+	fx.Provide(NewLoginLogRepository),
+	fx.Provide(NewDepartmentRepository),
+	fx.Provide(NewDepartmentLeaderRepository),
+	fx.Provide(NewUserDepartmentRepository),
+	fx.Provide(NewRoleDepartmentRepository),
 )
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+)
+
+// RoleDepartmentRepository database structure
+type RoleDepartmentRepository struct {
+	db     lib.Database
+	logger lib.Logger
+}
+
+// NewRoleDepartmentRepository creates a new role department repository
+func NewRoleDepartmentRepository(db lib.Database, logger lib.Logger) RoleDepartmentRepository {
+	return RoleDepartmentRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithTrx enables repository with transaction
+func (a RoleDepartmentRepository) WithTrx(trxHandle *gorm.DB) RoleDepartmentRepository {
+	if trxHandle == nil {
+		a.logger.Zap.Error("Transaction Database not found in echo context. ")
+		return a
+	}
+
+	a.db.ORM = trxHandle
+	return a
+}
+
+func (a RoleDepartmentRepository) Query(param *models.RoleDepartmentQueryParam) (*models.RoleDepartmentQueryResult, error) {
+	db := a.db.ORM.Model(&models.RoleDepartment{})
+
+	if v := param.RoleID; v != "" {
+		db = db.Where("role_id=?", v)
+	}
+
+	if v := param.RoleIDs; len(v) > 0 {
+		db = db.Where("role_id IN (?)", v)
+	}
+
+	db = db.Order(param.OrderParam.ParseOrder())
+
+	list := make(models.RoleDepartments, 0)
+	pagination, err := QueryPagination(db, param.PaginationParam, &list)
+	if err != nil {
+		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
+	}
+
+	qr := &models.RoleDepartmentQueryResult{
+		Pagination: pagination,
+		List:       list,
+	}
+
+	return qr, nil
+}
+
+func (a RoleDepartmentRepository) Create(roleDepartment *models.RoleDepartment) error {
+	result := a.db.ORM.Model(roleDepartment).Create(roleDepartment)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a RoleDepartmentRepository) Delete(id string) error {
+	roleDepartment := new(models.RoleDepartment)
+
+	result := a.db.ORM.Model(roleDepartment).Where("id=?", id).Delete(roleDepartment)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a RoleDepartmentRepository) DeleteByRoleID(id string) error {
+	roleDepartment := new(models.RoleDepartment)
+
+	result := a.db.ORM.Model(roleDepartment).Where("role_id=?", id).Delete(roleDepartment)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a RoleDepartmentRepository) DeleteByDepartmentID(id string) error {
+	roleDepartment := new(models.RoleDepartment)
+
+	result := a.db.ORM.Model(roleDepartment).Where("department_id=?", id).Delete(roleDepartment)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
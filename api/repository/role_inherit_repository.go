@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+)
+
+// RoleInheritRepository database structure
+type RoleInheritRepository struct {
+	db     lib.Database
+	logger lib.Logger
+}
+
+// NewRoleInheritRepository creates a new role inherit repository
+func NewRoleInheritRepository(db lib.Database, logger lib.Logger) RoleInheritRepository {
+	return RoleInheritRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithTrx enables repository with transaction
+func (a RoleInheritRepository) WithTrx(trxHandle *gorm.DB) RoleInheritRepository {
+	if trxHandle == nil {
+		a.logger.Zap.Error("Transaction Database not found in echo context. ")
+		return a
+	}
+
+	a.db.ORM = trxHandle
+	return a
+}
+
+func (a RoleInheritRepository) Query(param *models.RoleInheritQueryParam) (*models.RoleInheritQueryResult, error) {
+	db := a.db.ORM.Model(&models.RoleInherit{})
+
+	if v := param.RoleID; v != "" {
+		db = db.Where("role_id=?", v)
+	}
+
+	if v := param.RoleIDs; len(v) > 0 {
+		db = db.Where("role_id IN (?)", v)
+	}
+
+	if v := param.InheritID; v != "" {
+		db = db.Where("inherit_id=?", v)
+	}
+
+	db = db.Order(param.OrderParam.ParseOrder())
+
+	list := make(models.RoleInherits, 0)
+	pagination, err := QueryPagination(db, param.PaginationParam, &list)
+	if err != nil {
+		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
+	}
+
+	qr := &models.RoleInheritQueryResult{
+		Pagination: pagination,
+		List:       list,
+	}
+
+	return qr, nil
+}
+
+func (a RoleInheritRepository) Create(roleInherit *models.RoleInherit) error {
+	result := a.db.ORM.Model(roleInherit).Create(roleInherit)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a RoleInheritRepository) Delete(id string) error {
+	roleInherit := new(models.RoleInherit)
+
+	result := a.db.ORM.Model(roleInherit).Where("id=?", id).Delete(roleInherit)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a RoleInheritRepository) DeleteByRoleID(id string) error {
+	roleInherit := new(models.RoleInherit)
+
+	result := a.db.ORM.Model(roleInherit).Where("role_id=?", id).Delete(roleInherit)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
@@ -44,6 +44,10 @@ func (a RoleMenuRepository) Query(param *models.RoleMenuQueryParam) (*models.Rol
 		db = db.Where("role_id IN (?)", v)
 	}
 
+	if v := param.MenuID; v != "" {
+		db = db.Where("menu_id=?", v)
+	}
+
 	db = db.Order(param.OrderParam.ParseOrder())
 
 	list := make([]*models.RoleMenu, 0)
@@ -40,6 +40,10 @@ func (a RoleRepository) Query(param *models.RoleQueryParam) (*models.RoleQueryRe
 		db = db.Where("id IN (?)", v)
 	}
 
+	if v := param.Names; len(v) > 0 {
+		db = db.Where("name IN (?)", v)
+	}
+
 	if v := param.Name; v != "" {
 		db = db.Where("name=?", v)
 	}
@@ -57,6 +61,10 @@ func (a RoleRepository) Query(param *models.RoleQueryParam) (*models.RoleQueryRe
 		db = db.Where("name LIKE ? OR remark LIKE ?", v, v)
 	}
 
+	if v := param.Status; v != 0 {
+		db = db.Where("status=?", v)
+	}
+
 	db = db.Order(param.OrderParam.ParseOrder())
 
 	list := make(models.Roles, 0)
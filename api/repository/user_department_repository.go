@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+)
+
+// UserDepartmentRepository database structure
+type UserDepartmentRepository struct {
+	db     lib.Database
+	logger lib.Logger
+}
+
+// NewUserDepartmentRepository creates a new user department repository
+func NewUserDepartmentRepository(db lib.Database, logger lib.Logger) UserDepartmentRepository {
+	return UserDepartmentRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithTrx enables repository with transaction
+func (a UserDepartmentRepository) WithTrx(trxHandle *gorm.DB) UserDepartmentRepository {
+	if trxHandle == nil {
+		a.logger.Zap.Error("Transaction Database not found in echo context. ")
+		return a
+	}
+
+	a.db.ORM = trxHandle
+	return a
+}
+
+func (a UserDepartmentRepository) Query(param *models.UserDepartmentQueryParam) (*models.UserDepartmentQueryResult, error) {
+	db := a.db.ORM.Model(&models.UserDepartment{})
+
+	if v := param.UserID; v != "" {
+		db = db.Where("user_id=?", v)
+	}
+
+	if v := param.UserIDs; len(v) > 0 {
+		db = db.Where("user_id IN (?)", v)
+	}
+
+	if v := param.DepartmentIDs; len(v) > 0 {
+		db = db.Where("department_id IN (?)", v)
+	}
+
+	db = db.Order(param.OrderParam.ParseOrder())
+
+	list := make(models.UserDepartments, 0)
+	pagination, err := QueryPagination(db, param.PaginationParam, &list)
+	if err != nil {
+		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
+	}
+
+	qr := &models.UserDepartmentQueryResult{
+		Pagination: pagination,
+		List:       list,
+	}
+
+	return qr, nil
+}
+
+func (a UserDepartmentRepository) Create(userDepartment *models.UserDepartment) error {
+	result := a.db.ORM.Model(userDepartment).Create(userDepartment)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a UserDepartmentRepository) Update(id string, userDepartment *models.UserDepartment) error {
+	result := a.db.ORM.Model(userDepartment).Where("id=?", id).Update("is_primary", userDepartment.Primary)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a UserDepartmentRepository) Delete(id string) error {
+	userDepartment := new(models.UserDepartment)
+
+	result := a.db.ORM.Model(userDepartment).Where("id=?", id).Delete(userDepartment)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a UserDepartmentRepository) DeleteByUserID(userID string) error {
+	userDepartment := new(models.UserDepartment)
+
+	result := a.db.ORM.Model(userDepartment).Where("user_id=?", userID).Delete(userDepartment)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
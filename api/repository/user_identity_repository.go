@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+)
+
+// UserIdentityRepository database structure
+type UserIdentityRepository struct {
+	db     lib.Database
+	logger lib.Logger
+}
+
+// NewUserIdentityRepository creates a new user identity repository
+func NewUserIdentityRepository(db lib.Database, logger lib.Logger) UserIdentityRepository {
+	return UserIdentityRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithTrx enables repository with transaction
+func (a UserIdentityRepository) WithTrx(trxHandle *gorm.DB) UserIdentityRepository {
+	if trxHandle == nil {
+		a.logger.Zap.Error("Transaction Database not found in echo context. ")
+		return a
+	}
+
+	a.db.ORM = trxHandle
+	return a
+}
+
+func (a UserIdentityRepository) GetBySubject(provider, subject string) (*models.UserIdentity, error) {
+	identity := new(models.UserIdentity)
+
+	db := a.db.ORM.Model(identity).Where("provider=? AND subject=?", provider, subject)
+	if ok, err := QueryOne(db, identity); err != nil {
+		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
+	} else if !ok {
+		return nil, errors.DatabaseRecordNotFound
+	}
+
+	return identity, nil
+}
+
+func (a UserIdentityRepository) Create(identity *models.UserIdentity) error {
+	result := a.db.ORM.Model(identity).Create(identity)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a UserIdentityRepository) DeleteByUserID(userID string) error {
+	identity := new(models.UserIdentity)
+
+	result := a.db.ORM.Model(identity).Where("user_id=?", userID).Delete(identity)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+)
+
+// UserPasswordHistoryRepository database structure
+type UserPasswordHistoryRepository struct {
+	db     lib.Database
+	logger lib.Logger
+}
+
+// NewUserPasswordHistoryRepository creates a new user password history repository
+func NewUserPasswordHistoryRepository(db lib.Database, logger lib.Logger) UserPasswordHistoryRepository {
+	return UserPasswordHistoryRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithTrx enables repository with transaction
+func (a UserPasswordHistoryRepository) WithTrx(trxHandle *gorm.DB) UserPasswordHistoryRepository {
+	if trxHandle == nil {
+		a.logger.Zap.Error("Transaction Database not found in echo context. ")
+		return a
+	}
+
+	a.db.ORM = trxHandle
+	return a
+}
+
+func (a UserPasswordHistoryRepository) Query(param *models.UserPasswordHistoryQueryParam) (*models.UserPasswordHistoryQueryResult, error) {
+	db := a.db.ORM.Model(models.UserPasswordHistory{})
+
+	if v := param.UserID; v != "" {
+		db = db.Where("user_id=?", v)
+	}
+
+	db = db.Order(param.OrderParam.ParseOrder())
+
+	list := make(models.UserPasswordHistories, 0)
+	pagination, err := QueryPagination(db, param.PaginationParam, &list)
+	if err != nil {
+		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
+	}
+
+	qr := &models.UserPasswordHistoryQueryResult{
+		Pagination: pagination,
+		List:       list,
+	}
+
+	return qr, nil
+}
+
+func (a UserPasswordHistoryRepository) Create(history *models.UserPasswordHistory) error {
+	result := a.db.ORM.Model(history).Create(history)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+// DeleteBefore removes the history of the user older than the record,
+// the hashes are not kept beyond the history size
+func (a UserPasswordHistoryRepository) DeleteBefore(userID string, recordID uint) error {
+	history := new(models.UserPasswordHistory)
+
+	result := a.db.ORM.Unscoped().Model(history).Where("user_id=? AND record_id<?", userID, recordID).Delete(history)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a UserPasswordHistoryRepository) DeleteByUserID(userID string) error {
+	history := new(models.UserPasswordHistory)
+
+	result := a.db.ORM.Model(history).Where("user_id=?", userID).Delete(history)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+)
+
+// UserRecoveryCodeRepository database structure
+type UserRecoveryCodeRepository struct {
+	db     lib.Database
+	logger lib.Logger
+}
+
+// NewUserRecoveryCodeRepository creates a new user recovery code repository
+func NewUserRecoveryCodeRepository(db lib.Database, logger lib.Logger) UserRecoveryCodeRepository {
+	return UserRecoveryCodeRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// WithTrx enables repository with transaction
+func (a UserRecoveryCodeRepository) WithTrx(trxHandle *gorm.DB) UserRecoveryCodeRepository {
+	if trxHandle == nil {
+		a.logger.Zap.Error("Transaction Database not found in echo context. ")
+		return a
+	}
+
+	a.db.ORM = trxHandle
+	return a
+}
+
+func (a UserRecoveryCodeRepository) Query(param *models.UserRecoveryCodeQueryParam) (*models.UserRecoveryCodeQueryResult, error) {
+	db := a.db.ORM.Model(models.UserRecoveryCode{})
+
+	if v := param.UserID; v != "" {
+		db = db.Where("user_id=?", v)
+	}
+	if v := param.Code; v != "" {
+		db = db.Where("code=?", v)
+	}
+
+	db = db.Order(param.OrderParam.ParseOrder())
+
+	list := make(models.UserRecoveryCodes, 0)
+	pagination, err := QueryPagination(db, param.PaginationParam, &list)
+	if err != nil {
+		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
+	}
+
+	qr := &models.UserRecoveryCodeQueryResult{
+		Pagination: pagination,
+		List:       list,
+	}
+
+	return qr, nil
+}
+
+func (a UserRecoveryCodeRepository) Create(code *models.UserRecoveryCode) error {
+	result := a.db.ORM.Model(code).Create(code)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+// Delete removes the code, the returned count tells whether it was still unused
+func (a UserRecoveryCodeRepository) Delete(id string) (int64, error) {
+	code := new(models.UserRecoveryCode)
+
+	result := a.db.ORM.Model(code).Where("id=?", id).Delete(code)
+	if result.Error != nil {
+		return 0, errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (a UserRecoveryCodeRepository) DeleteByUserID(userID string) error {
+	code := new(models.UserRecoveryCode)
+
+	result := a.db.ORM.Model(code).Where("user_id=?", userID).Delete(code)
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
@@ -1,11 +1,14 @@
 package repository
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 
 	"github.com/RealLiuSha/echo-admin/errors"
 	"github.com/RealLiuSha/echo-admin/lib"
 	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/models/database"
 )
 
 // UserRepository database structure
@@ -33,18 +36,57 @@ func (a UserRepository) WithTrx(trxHandle *gorm.DB) UserRepository {
 	return a
 }
 
+// WithoutDataScope lifts the data scope of the current user, e.g. to check that a username is unique
+func (a UserRepository) WithoutDataScope() UserRepository {
+	if a.db.ORM.Statement.Context == nil {
+		return a
+	}
+
+	a.db.ORM = a.db.ORM.WithContext(lib.WithDataScope(a.db.ORM.Statement.Context, nil))
+	return a
+}
+
+// DataScope returns the data scope of the current user, nil when it reaches all users
+func (a UserRepository) DataScope() (*lib.DataScope, error) {
+	return lib.DataScopeOf(a.db.ORM)
+}
+
+// scoped limits the query to the current user and the users of the departments of its data scope
+func (a UserRepository) scoped(db *gorm.DB) (*gorm.DB, error) {
+	scope, err := a.DataScope()
+	if err != nil {
+		return nil, err
+	} else if scope == nil {
+		return db, nil
+	}
+
+	if len(scope.DepartmentIDs) == 0 {
+		return db.Where("id = ?", scope.UserID), nil
+	}
+
+	subQuery := a.db.ORM.Model(&models.UserDepartment{}).
+		Select("user_id").
+		Where("department_id IN (?)", scope.DepartmentIDs)
+
+	return db.Where("id = ? OR id IN (?)", scope.UserID, subQuery), nil
+}
+
 // GetAll gets all users
 func (a UserRepository) Query(param *models.UserQueryParam) (*models.UserQueryResult, error) {
 	db := a.db.ORM.Model(&models.User{})
 
 	if v := param.QueryPassword; !v {
-		db = db.Omit("password")
+		db = db.Omit("password", "two_factor_secret")
 	}
 
 	if v := param.Username; v != "" {
 		db = db.Where("username = (?)", v)
 	}
 
+	if v := param.Email; v != "" {
+		db = db.Where("email = (?)", v)
+	}
+
 	if v := param.Realname; v != "" {
 		db = db.Where("realname = (?)", v)
 	}
@@ -61,6 +103,19 @@ func (a UserRepository) Query(param *models.UserQueryParam) (*models.UserQueryRe
 		db = db.Where("id IN (?)", subQuery)
 	}
 
+	if v := param.DepartmentIDs; len(v) > 0 {
+		subQuery := a.db.ORM.Model(&models.UserDepartment{}).
+			Select("user_id").
+			Where("department_id IN (?)", v)
+
+		db = db.Where("id IN (?)", subQuery)
+	}
+
+	db, err := a.scoped(db)
+	if err != nil {
+		return nil, err
+	}
+
 	if v := param.QueryValue; v != "" {
 		v = "%" + v + "%"
 		db = db.Where("username LIKE ? OR realname LIKE ? OR phone LIKE ? OR email LIKE ?", v, v, v, v)
@@ -85,7 +140,12 @@ func (a UserRepository) Query(param *models.UserQueryParam) (*models.UserQueryRe
 func (a UserRepository) Get(id string) (*models.User, error) {
 	user := new(models.User)
 
-	if ok, err := QueryOne(a.db.ORM.Model(user).Where("id=?", id), user); err != nil {
+	db, err := a.scoped(a.db.ORM.Model(user).Where("id=?", id))
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, err := QueryOne(db, user); err != nil {
 		return nil, errors.Wrap(errors.DatabaseInternalError, err.Error())
 	} else if !ok {
 		return nil, errors.DatabaseRecordNotFound
@@ -104,7 +164,12 @@ func (a UserRepository) Create(user *models.User) error {
 }
 
 func (a UserRepository) Update(id string, user *models.User) error {
-	result := a.db.ORM.Model(user).Where("id=?", id).Updates(user)
+	db, err := a.scoped(a.db.ORM.Model(user).Where("id=?", id))
+	if err != nil {
+		return err
+	}
+
+	result := db.Updates(user)
 	if result.Error != nil {
 		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
 	}
@@ -115,7 +180,12 @@ func (a UserRepository) Update(id string, user *models.User) error {
 func (a UserRepository) Delete(id string) error {
 	user := new(models.User)
 
-	result := a.db.ORM.Model(user).Where("id=?", id).Delete(user)
+	db, err := a.scoped(a.db.ORM.Model(user).Where("id=?", id))
+	if err != nil {
+		return err
+	}
+
+	result := db.Delete(user)
 	if result.Error != nil {
 		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
 	}
@@ -126,7 +196,12 @@ func (a UserRepository) Delete(id string) error {
 func (a UserRepository) UpdateStatus(id string, status int) error {
 	user := new(models.User)
 
-	result := a.db.ORM.Model(user).Where("id=?", id).Update("status", status)
+	db, err := a.scoped(a.db.ORM.Model(user).Where("id=?", id))
+	if err != nil {
+		return err
+	}
+
+	result := db.Update("status", status)
 	if result.Error != nil {
 		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
 	}
@@ -144,3 +219,65 @@ func (a UserRepository) UpdatePassword(id, password string) error {
 
 	return nil
 }
+
+func (a UserRepository) UpdateLastLogin(id string, at database.Datetime, ip string) error {
+	user := new(models.User)
+
+	result := a.db.ORM.Model(user).Where("id=?", id).UpdateColumns(map[string]interface{}{
+		"last_login_at": at,
+		"last_login_ip": ip,
+	})
+
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a UserRepository) UpdateProfile(id string, profile *models.UserProfile) error {
+	user := new(models.User)
+
+	result := a.db.ORM.Model(user).Where("id=?", id).Updates(map[string]interface{}{
+		"realname": profile.Realname,
+		"email":    profile.Email,
+		"phone":    profile.Phone,
+	})
+
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the password and restarts its expiry
+func (a UserRepository) ChangePassword(id, password string) error {
+	user := new(models.User)
+
+	result := a.db.ORM.Model(user).Where("id=?", id).Updates(map[string]interface{}{
+		"password":            password,
+		"password_changed_at": database.Datetime{Time: time.Now(), Valid: true},
+	})
+
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
+
+func (a UserRepository) UpdateTwoFactor(id, secret string, enabled bool) error {
+	user := new(models.User)
+
+	result := a.db.ORM.Model(user).Where("id=?", id).Updates(map[string]interface{}{
+		"two_factor_secret":  secret,
+		"two_factor_enabled": enabled,
+	})
+
+	if result.Error != nil {
+		return errors.Wrap(errors.DatabaseInternalError, result.Error.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"github.com/RealLiuSha/echo-admin/api/controllers"
+	"github.com/RealLiuSha/echo-admin/lib"
+)
+
+type LoginLogRoutes struct {
+	logger             lib.Logger
+	handler            lib.HttpHandler
+	loginLogController controllers.LoginLogController
+}
+
+// NewLoginLogRoutes creates new login log routes
+func NewLoginLogRoutes(
+	logger lib.Logger,
+	handler lib.HttpHandler,
+	loginLogController controllers.LoginLogController,
+) LoginLogRoutes {
+	return LoginLogRoutes{
+		handler:            handler,
+		logger:             logger,
+		loginLogController: loginLogController,
+	}
+}
+
+// Setup login log routes
+func (a LoginLogRoutes) Setup() {
+	a.logger.Zap.Info("Setting up login log routes")
+	api := a.handler.RouterV1.Group("/login-logs")
+	{
+		api.GET("", a.loginLogController.Query)
+	}
+}
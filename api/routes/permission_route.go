@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"github.com/RealLiuSha/echo-admin/api/controllers"
+	"github.com/RealLiuSha/echo-admin/lib"
+)
+
+type PermissionRoutes struct {
+	logger               lib.Logger
+	handler              lib.HttpHandler
+	permissionController controllers.PermissionController
+}
+
+// NewPermissionRoutes creates new permission routes
+func NewPermissionRoutes(
+	logger lib.Logger,
+	handler lib.HttpHandler,
+	permissionController controllers.PermissionController,
+) PermissionRoutes {
+	return PermissionRoutes{
+		handler:              handler,
+		logger:               logger,
+		permissionController: permissionController,
+	}
+}
+
+// Setup permission routes
+func (a PermissionRoutes) Setup() {
+	a.logger.Zap.Info("Setting up permission routes")
+	api := a.handler.RouterV1.Group("/permissions")
+	{
+		api.GET("/check", a.permissionController.Check)
+		api.POST("/check", a.permissionController.CheckAll)
+	}
+}
@@ -30,13 +30,32 @@ func NewPublicRoutes(
 // Setup public routes
 func (a PublicRoutes) Setup() {
 	a.logger.Zap.Info("Setting up public routes")
+	a.handler.Engine.GET("/.well-known/jwks.json", a.publicController.JWKS)
+
 	api := a.handler.RouterV1.Group("/publics")
 	{
 		api.GET("/user", a.publicController.UserInfo)
 		api.POST("/user/login", a.publicController.UserLogin)
+		api.POST("/user/login/2fa", a.publicController.UserLoginTwoFactor)
+		api.POST("/user/refresh", a.publicController.UserRefresh)
 		api.POST("/user/logout", a.publicController.UserLogout)
 		api.GET("/user/menutree", a.publicController.MenuTree)
-		//api.GET("/user/password", a.publicController.UserPassword)
+		api.GET("/user/permissions", a.publicController.UserPermissions)
+		api.GET("/user/sessions", a.publicController.UserSessions)
+		api.DELETE("/user/sessions/:id", a.publicController.UserDestroySession)
+		api.GET("/user/logins", a.publicController.UserLoginLogs)
+		api.POST("/user/2fa/enroll", a.publicController.UserTwoFactorEnroll)
+		api.POST("/user/2fa/activate", a.publicController.UserTwoFactorActivate)
+		api.POST("/user/2fa/recovery-codes", a.publicController.UserTwoFactorRecoveryCodes)
+		api.POST("/user/2fa/disable", a.publicController.UserTwoFactorDisable)
+		api.PUT("/user/password", a.publicController.UserPassword)
+		api.POST("/user/password/forgot", a.publicController.UserForgotPassword)
+		api.POST("/user/password/reset", a.publicController.UserResetPassword)
+		api.PUT("/user/profile", a.publicController.UserProfile)
+
+		// oidc
+		api.GET("/oidc/login", a.publicController.OIDCLogin)
+		api.GET("/oidc/callback", a.publicController.OIDCCallback)
 
 		// sys routes
 		api.GET("/sys/routes", a.publicController.SysRoutes)
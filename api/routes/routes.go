@@ -10,6 +10,9 @@ var Module = fx.Options(
 	fx.Provide(NewUserRoutes),
 	fx.Provide(NewRoleRoutes),
 	fx.Provide(NewMenuRoutes),
+	fx.Provide(NewLoginLogRoutes),
+	fx.Provide(NewDepartmentRoutes),
+	fx.Provide(NewPermissionRoutes),
 	fx.Provide(NewRoutes),
 )
 
@@ -29,6 +32,9 @@ func NewRoutes(
 	userRoutes UserRoutes,
 	roleRoutes RoleRoutes,
 	menuRoutes MenuRoutes,
+	loginLogRoutes LoginLogRoutes,
+	departmentRoutes DepartmentRoutes,
+	permissionRoutes PermissionRoutes,
 ) Routes {
 	return Routes{
 		pprofRoutes,
@@ -37,6 +43,9 @@ func NewRoutes(
 		userRoutes,
 		roleRoutes,
 		menuRoutes,
+		loginLogRoutes,
+		departmentRoutes,
+		permissionRoutes,
 	}
 }
 
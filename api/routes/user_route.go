@@ -36,5 +36,14 @@ func (a UserRoutes) Setup() {
 		api.DELETE("/:id", a.userController.Delete)
 		api.POST("/:id/enable", a.userController.Enable)
 		api.POST("/:id/disable", a.userController.Disable)
+		api.GET("/:id/sessions", a.userController.Sessions)
+		api.DELETE("/:id/sessions", a.userController.DestroySessions)
+		api.DELETE("/:id/sessions/:sid", a.userController.DestroySession)
+		api.DELETE("/:id/2fa", a.userController.ResetTwoFactor)
+		api.POST("/:id/unlock", a.userController.Unlock)
+		api.POST("/:id/impersonate", a.userController.Impersonate)
+		api.GET("/:id/tokens", a.userController.AccessTokens)
+		api.POST("/:id/tokens", a.userController.CreateAccessToken)
+		api.DELETE("/:id/tokens/:tid", a.userController.DestroyAccessToken)
 	}
 }
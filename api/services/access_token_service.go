@@ -0,0 +1,229 @@
+package services
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/api/repository"
+	"github.com/RealLiuSha/echo-admin/constants"
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+	"github.com/RealLiuSha/echo-admin/pkg/hash"
+	"github.com/RealLiuSha/echo-admin/pkg/random"
+	"github.com/RealLiuSha/echo-admin/pkg/uuid"
+)
+
+// the last used time of a token is written at most once per interval
+const accessTokenTouchInterval = time.Minute
+
+// AccessTokenService manages the access tokens of service accounts, they are issued by admins
+type AccessTokenService struct {
+	logger                lib.Logger
+	userRepository        repository.UserRepository
+	accessTokenRepository repository.AccessTokenRepository
+}
+
+// NewAccessTokenService creates a new access token service
+func NewAccessTokenService(
+	logger lib.Logger,
+	userRepository repository.UserRepository,
+	accessTokenRepository repository.AccessTokenRepository,
+) AccessTokenService {
+	return AccessTokenService{
+		logger:                logger,
+		userRepository:        userRepository,
+		accessTokenRepository: accessTokenRepository,
+	}
+}
+
+// WithTrx delegates transaction to repository database
+func (a AccessTokenService) WithTrx(trxHandle *gorm.DB) AccessTokenService {
+	a.userRepository = a.userRepository.WithTrx(trxHandle)
+	a.accessTokenRepository = a.accessTokenRepository.WithTrx(trxHandle)
+
+	return a
+}
+
+func (a AccessTokenService) Query(userID string) (*models.AccessTokenQueryResult, error) {
+	if _, err := a.userRepository.Get(userID); err != nil {
+		return nil, err
+	}
+
+	return a.accessTokenRepository.Query(&models.AccessTokenQueryParam{UserID: userID})
+}
+
+// Check validates the param again, the binder skips the validation of pointers
+func (a AccessTokenService) Check(param *dto.CreateAccessToken) error {
+	if param.Name == "" || utf8.RuneCountInString(param.Name) > 64 {
+		return errors.AccessTokenInvalidName
+	}
+
+	if len(param.Scopes) == 0 {
+		return errors.AccessTokenInvalidScopes
+	}
+
+	for _, scope := range param.Scopes {
+		if scope != constants.AccessTokenScopeRead && scope != constants.AccessTokenScopeWrite {
+			return errors.AccessTokenInvalidScopes
+		}
+	}
+
+	if param.ExpiresIn < 0 {
+		return errors.AccessTokenInvalidExpires
+	}
+
+	return nil
+}
+
+// Create issues a token for the service account, the token itself is only returned here
+func (a AccessTokenService) Create(userID, createdBy string, param *dto.CreateAccessToken) (*dto.AccessTokenSecret, error) {
+	if err := a.Check(param); err != nil {
+		return nil, err
+	}
+
+	user, err := a.userRepository.Get(userID)
+	if err != nil {
+		return nil, err
+	} else if user.Status != 1 {
+		return nil, errors.UserIsDisable
+	} else if !user.ServiceAccount {
+		return nil, errors.AccessTokenNotAllowed
+	}
+
+	secret := constants.AccessTokenPrefix + random.Token(32)
+	token := &models.AccessToken{
+		ID:        uuid.MustString(),
+		UserID:    user.ID,
+		Name:      param.Name,
+		Token:     hash.SHA256(secret),
+		Prefix:    secret[:len(constants.AccessTokenPrefix)+8],
+		Scopes:    strings.Join(param.Scopes, ","),
+		CreatedBy: createdBy,
+	}
+
+	if param.ExpiresIn > 0 {
+		token.ExpiresAt = database.Datetime{
+			Time:  time.Now().AddDate(0, 0, param.ExpiresIn),
+			Valid: true,
+		}
+	}
+
+	if err := a.accessTokenRepository.Create(token); err != nil {
+		return nil, err
+	}
+
+	a.logger.Security("access_token_created",
+		"user_id", user.ID,
+		"username", user.Username,
+		"token_id", token.ID,
+		"prefix", token.Prefix,
+		"scopes", token.Scopes,
+		"expires_in", param.ExpiresIn,
+		"created_by", createdBy,
+	)
+
+	return &dto.AccessTokenSecret{ID: token.ID, Token: secret}, nil
+}
+
+// Delete revokes the token of the user
+func (a AccessTokenService) Delete(userID, id, deletedBy string) error {
+	if _, err := a.userRepository.Get(userID); err != nil {
+		return err
+	}
+
+	token, err := a.accessTokenRepository.Get(id)
+	if err != nil {
+		if errors.Is(err, errors.DatabaseRecordNotFound) {
+			return errors.AccessTokenNotFound
+		}
+
+		return err
+	} else if token.UserID != userID {
+		return errors.AccessTokenNotFound
+	}
+
+	if err := a.accessTokenRepository.Delete(id); err != nil {
+		return err
+	}
+
+	a.logger.Security("access_token_revoked",
+		"user_id", userID,
+		"token_id", token.ID,
+		"prefix", token.Prefix,
+		"revoked_by", deletedBy,
+	)
+
+	return nil
+}
+
+// DeleteByUserID revokes all the tokens of the user
+func (a AccessTokenService) DeleteByUserID(userID, reason string) error {
+	tokenQR, err := a.accessTokenRepository.Query(&models.AccessTokenQueryParam{UserID: userID})
+	if err != nil {
+		return err
+	} else if tokenQR.Pagination.Total == 0 {
+		return nil
+	}
+
+	if err := a.accessTokenRepository.DeleteByUserID(userID); err != nil {
+		return err
+	}
+
+	a.logger.Security("access_tokens_revoked",
+		"user_id", userID,
+		"count", tokenQR.Pagination.Total,
+		"reason", reason,
+	)
+
+	return nil
+}
+
+// Parse authenticates the token, the claims carry the user of the token
+// so that the same role policies apply to it
+func (a AccessTokenService) Parse(secret string) (*dto.JwtClaims, error) {
+	tokenQR, err := a.accessTokenRepository.Query(&models.AccessTokenQueryParam{
+		Token: hash.SHA256(secret),
+	})
+
+	if err != nil {
+		return nil, err
+	} else if len(tokenQR.List) == 0 {
+		return nil, errors.AuthTokenInvalid
+	}
+
+	token := tokenQR.List[0]
+	if token.ExpiresAt.Valid && time.Now().After(token.ExpiresAt.Time) {
+		return nil, errors.AuthTokenExpired
+	}
+
+	user, err := a.userRepository.Get(token.UserID)
+	if err != nil {
+		if errors.Is(err, errors.DatabaseRecordNotFound) {
+			return nil, errors.AuthTokenRevoked
+		}
+
+		return nil, err
+	} else if user.Status != 1 {
+		return nil, errors.UserIsDisable
+	}
+
+	if !token.LastUsedAt.Valid || time.Since(token.LastUsedAt.Time) > accessTokenTouchInterval {
+		now := database.Datetime{Time: time.Now(), Valid: true}
+		if err := a.accessTokenRepository.UpdateLastUsed(token.ID, now); err != nil {
+			a.logger.Zap.Errorf("Error to update the last used time of access token %s: %v", token.ID, err)
+		}
+	}
+
+	return &dto.JwtClaims{
+		ID:            user.ID,
+		Username:      user.Username,
+		AccessTokenID: token.ID,
+		Scopes:        token.SplitScopes(),
+	}, nil
+}
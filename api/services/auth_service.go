@@ -2,23 +2,45 @@ package services
 
 import (
 	"fmt"
+	"io/ioutil"
+	"sort"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 
+	"github.com/RealLiuSha/echo-admin/constants"
 	"github.com/RealLiuSha/echo-admin/errors"
 	"github.com/RealLiuSha/echo-admin/lib"
 	"github.com/RealLiuSha/echo-admin/models"
 	"github.com/RealLiuSha/echo-admin/models/dto"
+	"github.com/RealLiuSha/echo-admin/pkg/hash"
+	"github.com/RealLiuSha/echo-admin/pkg/jwk"
+	"github.com/RealLiuSha/echo-admin/pkg/random"
+	"github.com/RealLiuSha/echo-admin/pkg/slice"
+	"github.com/RealLiuSha/echo-admin/pkg/uuid"
 )
 
+// the last seen time of a session is updated at most once per minute
+const sessionTouchInterval = 60
+
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	// private or shared key, nil when the key is only kept to verify tokens
+	sign   interface{}
+	verify interface{}
+}
+
 type options struct {
-	issuer        string
-	signingMethod jwt.SigningMethod
-	signingKey    interface{}
-	keyfunc       jwt.Keyfunc
-	expired       int
-	tokenType     string
+	issuer         string
+	signingKey     *signingKey
+	verifyingKeys  map[string]*signingKey
+	keyfunc        jwt.Keyfunc
+	expired        int
+	refreshExpired int
+	// lifetime of impersonation tokens, they are never refreshed
+	impersonationExpired int
+	tokenType            string
 }
 
 type AuthService struct {
@@ -26,52 +48,334 @@ type AuthService struct {
 	redis lib.Redis
 }
 
-func NewAuthService(redis lib.Redis, config lib.Config) AuthService {
-	issuer := config.Name
-	signingKey := fmt.Sprintf("Jwt:%s", issuer)
-
+func NewAuthService(redis lib.Redis, config lib.Config, logger lib.Logger) AuthService {
 	opts := &options{
-		issuer:        issuer,
-		tokenType:     "Bearer",
-		expired:       config.Auth.TokenExpired,
-		signingMethod: jwt.SigningMethodHS512,
-		signingKey:    []byte(signingKey),
-		keyfunc: func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.AuthTokenInvalid
-			}
-			return []byte(signingKey), nil
-		},
+		issuer:         config.Name,
+		tokenType:      "Bearer",
+		expired:        config.Auth.TokenExpired,
+		refreshExpired: config.Auth.RefreshTokenExpired,
+		verifyingKeys:  make(map[string]*signingKey),
+	}
+
+	if conf := config.Auth.Impersonation; conf != nil && conf.Expired > 0 {
+		opts.impersonationExpired = conf.Expired
+	} else {
+		opts.impersonationExpired = 900
+	}
+
+	keys, err := loadSigningKeys(config.Auth.SigningKeys)
+	if err != nil {
+		logger.Zap.Fatalf("Error to load auth signing keys: %v", err)
+	}
+
+	if len(keys) == 0 {
+		logger.Zap.Warn("Auth.SigningKeys is not configured, tokens are signed with a key derived from the app name")
+		secret := []byte(fmt.Sprintf("Jwt:%s", config.Name))
+		keys = append(keys, &signingKey{method: jwt.SigningMethodHS512, sign: secret, verify: secret})
+	}
+
+	for _, key := range keys {
+		opts.verifyingKeys[key.id] = key
+		if key.sign == nil {
+			continue
+		}
+
+		if opts.signingKey == nil || key.id == config.Auth.SigningKeyID {
+			opts.signingKey = key
+		}
+	}
+
+	if opts.signingKey == nil {
+		logger.Zap.Fatal("Error to find auth signing key: no key with a private key or secret")
+	} else if id := config.Auth.SigningKeyID; id != "" && opts.signingKey.id != id {
+		logger.Zap.Fatalf("Error to find auth signing key: %s", id)
+	}
+
+	opts.keyfunc = func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		key, ok := opts.verifyingKeys[kid]
+		if !ok || key.method.Alg() != t.Method.Alg() {
+			return nil, errors.AuthTokenInvalid
+		}
+
+		return key.verify, nil
 	}
 
 	return AuthService{redis: redis, opts: opts}
 }
 
-func wrapperAuthKey(key string) string {
-	return fmt.Sprintf("auth:%s", key)
+func loadSigningKeys(configs []*lib.SigningKeyConfig) ([]*signingKey, error) {
+	keys := make([]*signingKey, 0, len(configs))
+	ids := make(map[string]struct{})
+
+	for _, item := range configs {
+		if _, ok := ids[item.ID]; ok {
+			return nil, fmt.Errorf("key %s: duplicate key id", item.ID)
+		}
+		ids[item.ID] = struct{}{}
+
+		method := jwt.GetSigningMethod(item.Algorithm)
+		if method == nil {
+			return nil, fmt.Errorf("key %s: unsupported algorithm %s", item.ID, item.Algorithm)
+		}
+
+		key := &signingKey{id: item.ID, method: method}
+		switch method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if item.Secret == "" {
+				return nil, fmt.Errorf("key %s: secret is required", item.ID)
+			}
+
+			key.sign = []byte(item.Secret)
+			key.verify = key.sign
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+			var err error
+			if key.sign, key.verify, err = loadKeyPair(method, item); err != nil {
+				return nil, fmt.Errorf("key %s: %v", item.ID, err)
+			}
+		default:
+			return nil, fmt.Errorf("key %s: unsupported algorithm %s", item.ID, item.Algorithm)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// loadKeyPair reads the private key of a rsa/ecdsa key,
+// retired keys may only provide the public key to keep verifying issued tokens
+func loadKeyPair(method jwt.SigningMethod, config *lib.SigningKeyConfig) (interface{}, interface{}, error) {
+	_, isRSA := method.(*jwt.SigningMethodRSA)
+
+	if config.KeyFile != "" {
+		data, err := ioutil.ReadFile(config.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if isRSA {
+			key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			return key, &key.PublicKey, nil
+		}
+
+		key, err := jwt.ParseECPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, &key.PublicKey, nil
+	}
+
+	if config.PublicKeyFile != "" {
+		data, err := ioutil.ReadFile(config.PublicKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if isRSA {
+			key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			return nil, key, err
+		}
+
+		key, err := jwt.ParseECPublicKeyFromPEM(data)
+		return nil, key, err
+	}
+
+	return nil, nil, errors.New("key file is required")
+}
+
+// JWKS returns the public keys of the asymmetric signing keys,
+// other services can use them to verify the issued tokens
+func (a AuthService) JWKS() jwk.Set {
+	set := jwk.Set{Keys: make([]jwk.Key, 0)}
+	for _, key := range a.opts.verifyingKeys {
+		if _, ok := key.verify.([]byte); ok {
+			continue
+		}
+
+		if item, err := jwk.New(key.id, key.method.Alg(), key.verify); err == nil {
+			set.Keys = append(set.Keys, item)
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+func wrapperSessionKey(id string) string {
+	return fmt.Sprintf("auth:session:%s", id)
+}
+
+func wrapperUserSessionsKey(userID string) string {
+	return fmt.Sprintf("auth:user:%s", userID)
+}
+
+func wrapperRefreshKey(id string) string {
+	return fmt.Sprintf("auth:refresh:%s", id)
+}
+
+func wrapperRefreshRedeemKey(id string) string {
+	return fmt.Sprintf("auth:refresh:redeem:%s", id)
+}
+
+func wrapperSessionSeenKey(id string) string {
+	return fmt.Sprintf("auth:seen:%s", id)
+}
+
+// GenerateToken starts a new session for the user and issues its first token pair,
+// a session with restrictions only reaches the paths needed to lift them
+func (a AuthService) GenerateToken(user *models.User, client dto.Client, restrictions ...string) (*dto.TokenPair, error) {
+	session := &dto.Session{
+		ID:           uuid.MustString(),
+		UserID:       user.ID,
+		Username:     user.Username,
+		IP:           client.IP,
+		UserAgent:    client.UserAgent,
+		Restrictions: restrictions,
+	}
+
+	session.IssuedAt = time.Now().Unix()
+	session.LastSeenAt = session.IssuedAt
+
+	return a.issueToken(session, a.opts.refreshExpired > 0)
+}
+
+// Impersonate starts a session of the user for the admin, the short-lived token carries both ids
+// and is indexed under the user, so that revoking the sessions of the user also ends it
+func (a AuthService) Impersonate(admin *dto.JwtClaims, user *models.User, client dto.Client) (*dto.TokenPair, error) {
+	session := &dto.Session{
+		ID:               uuid.MustString(),
+		UserID:           user.ID,
+		Username:         user.Username,
+		IP:               client.IP,
+		UserAgent:        client.UserAgent,
+		ImpersonatorID:   admin.ID,
+		ImpersonatorName: admin.Username,
+	}
+
+	session.IssuedAt = time.Now().Unix()
+	session.LastSeenAt = session.IssuedAt
+
+	return a.issueToken(session, false)
+}
+
+// RefreshToken rotates the refresh token and issues a new token pair for its session,
+// presenting an already rotated refresh token revokes the whole session
+func (a AuthService) RefreshToken(refreshToken string) (*dto.TokenPair, error) {
+	var (
+		id        = hash.SHA256(refreshToken)
+		sessionID string
+	)
+
+	if err := a.redis.GetSkippingLocalCache(wrapperRefreshKey(id), &sessionID); err != nil {
+		if errors.Is(err, errors.RedisKeyNoExist) {
+			return nil, errors.AuthRefreshTokenInvalid
+		}
+
+		return nil, err
+	}
+
+	session, err := a.GetSession(sessionID)
+	if err != nil {
+		if errors.Is(err, errors.RedisKeyNoExist) {
+			return nil, errors.AuthRefreshTokenInvalid
+		}
+
+		return nil, err
+	}
+
+	if session.RefreshID != id || session.ImpersonatorID != "" {
+		if err := a.DestroySession(session.ID); err != nil {
+			return nil, err
+		}
+
+		return nil, errors.AuthRefreshTokenReused
+	}
+
+	// the token is redeemed by whoever counts first, a concurrent refresh with it is a reuse,
+	// the refresh key itself is kept so that a later reuse is still detected
+	expired := time.Duration(a.opts.refreshExpired) * time.Second
+	if n, err := a.redis.Incr(wrapperRefreshRedeemKey(id), expired); err != nil {
+		return nil, err
+	} else if n > 1 {
+		if err := a.DestroySession(session.ID); err != nil {
+			return nil, err
+		}
+
+		return nil, errors.AuthRefreshTokenReused
+	}
+
+	return a.issueToken(session, true)
 }
 
-func (a AuthService) GenerateToken(user *models.User) (string, error) {
+func (a AuthService) issueToken(session *dto.Session, refresh bool) (*dto.TokenPair, error) {
+	expired := a.opts.expired
+	if session.ImpersonatorID != "" {
+		expired = a.opts.impersonationExpired
+	}
+
 	now := time.Now()
 	claims := &dto.JwtClaims{
-		ID:       user.ID,
-		Username: user.Username,
+		ID:               session.UserID,
+		Username:         session.Username,
+		SessionID:        session.ID,
+		ImpersonatorID:   session.ImpersonatorID,
+		ImpersonatorName: session.ImpersonatorName,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: now.Add(time.Duration(a.opts.expired) * time.Second).Unix(),
+			Id:        uuid.MustString(),
+			Issuer:    a.opts.issuer,
+			ExpiresAt: now.Add(time.Duration(expired) * time.Second).Unix(),
 			IssuedAt:  now.Unix(),
 			NotBefore: now.Unix(),
 		},
 	}
 
-	token := jwt.NewWithClaims(a.opts.signingMethod, claims)
-	expired := time.Unix(claims.ExpiresAt, 0).Sub(time.Now())
+	key := a.opts.signingKey
+	jwtToken := jwt.NewWithClaims(key.method, claims)
+	if key.id != "" {
+		jwtToken.Header["kid"] = key.id
+	}
 
-	err := a.redis.Set(wrapperAuthKey(claims.Username), 1, expired)
+	token, err := jwtToken.SignedString(key.sign)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	pair := &dto.TokenPair{
+		TokenType:          a.opts.tokenType,
+		AccessToken:        token,
+		ExpiresIn:          expired,
+		Restrictions:       session.Restrictions,
+		MustChangePassword: slice.ContainsString(session.Restrictions, constants.SessionRestrictPassword),
+		ImpersonatedBy:     session.ImpersonatorName,
+	}
+
+	// only the latest access token of a session is accepted
+	session.TokenID = claims.Id
+	session.ExpiresAt = claims.ExpiresAt
+
+	if refresh {
+		pair.RefreshToken = random.Token(32)
+		expired := time.Duration(a.opts.refreshExpired) * time.Second
+
+		// rotated refresh tokens are kept until they expire so that reuse can be detected
+		session.RefreshID = hash.SHA256(pair.RefreshToken)
+		session.ExpiresAt = now.Add(expired).Unix()
+
+		if err := a.redis.Set(wrapperRefreshKey(session.RefreshID), session.ID, expired); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := a.SaveSession(session); err != nil {
+		return nil, err
 	}
 
-	return token.SignedString(a.opts.signingKey)
+	return pair, nil
 }
 
 func (a AuthService) ParseToken(tokenString string) (*dto.JwtClaims, error) {
@@ -90,16 +394,191 @@ func (a AuthService) ParseToken(tokenString string) (*dto.JwtClaims, error) {
 		}
 	}
 
-	if token != nil {
-		if claims, ok := token.Claims.(*dto.JwtClaims); ok && token.Valid {
-			return claims, nil
+	if token == nil || !token.Valid {
+		return nil, errors.AuthTokenInvalid
+	}
+
+	claims, ok := token.Claims.(*dto.JwtClaims)
+	if !ok || claims.SessionID == "" {
+		return nil, errors.AuthTokenInvalid
+	}
+
+	// the token is only accepted while its session is registered
+	session, err := a.GetSession(claims.SessionID)
+	if err != nil {
+		if errors.Is(err, errors.RedisKeyNoExist) {
+			return nil, errors.AuthTokenRevoked
+		}
+
+		return nil, err
+	} else if session.TokenID != claims.Id {
+		return nil, errors.AuthTokenRevoked
+	}
+
+	if err := a.touchSession(session); err != nil {
+		return nil, err
+	}
+
+	claims.Restrictions = session.Restrictions
+	claims.ImpersonatorID, claims.ImpersonatorName = session.ImpersonatorID, session.ImpersonatorName
+	return claims, nil
+}
+
+// LiftRestriction removes the restriction from the session once its step is completed
+func (a AuthService) LiftRestriction(sessionID, restriction string) error {
+	session, err := a.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	restrictions := make([]string, 0, len(session.Restrictions))
+	for _, item := range session.Restrictions {
+		if item != restriction {
+			restrictions = append(restrictions, item)
+		}
+	}
+
+	if len(restrictions) == len(session.Restrictions) {
+		return nil
+	}
+
+	session.Restrictions = restrictions
+	return a.SaveSession(session)
+}
+
+// touchSession records the activity of the session, at most once per interval.
+// It is kept apart from the session so that it never races with a token rotation
+func (a AuthService) touchSession(session *dto.Session) error {
+	now := time.Now()
+	if now.Unix()-session.LastSeenAt < sessionTouchInterval {
+		return nil
+	}
+
+	return a.redis.Set(wrapperSessionSeenKey(session.ID), now.Unix(), time.Until(time.Unix(session.ExpiresAt, 0)))
+}
+
+// SaveSession registers the session in redis,
+// and indexes it under the user so that it can be revoked with the user
+func (a AuthService) SaveSession(session *dto.Session) error {
+	expired := time.Until(time.Unix(session.ExpiresAt, 0))
+	if err := a.redis.Set(wrapperSessionKey(session.ID), session, expired); err != nil {
+		return err
+	}
+
+	key := wrapperUserSessionsKey(session.UserID)
+	if err := a.redis.SetAdd(key, session.ID); err != nil {
+		return err
+	}
+
+	// the index lives as long as the longest session of the user
+	ttl, err := a.redis.TTL(key)
+	if err != nil {
+		return err
+	} else if ttl < expired {
+		return a.redis.Expire(key, expired)
+	}
+
+	return nil
+}
+
+func (a AuthService) GetSession(id string) (*dto.Session, error) {
+	session := new(dto.Session)
+	if err := a.redis.GetSkippingLocalCache(wrapperSessionKey(id), session); err != nil {
+		return nil, err
+	}
+
+	err := a.redis.GetSkippingLocalCache(wrapperSessionSeenKey(id), &session.LastSeenAt)
+	if err != nil && !errors.Is(err, errors.RedisKeyNoExist) {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetUserSessions lists the active sessions of the user, latest first
+func (a AuthService) GetUserSessions(userID string) (dto.Sessions, error) {
+	key := wrapperUserSessionsKey(userID)
+
+	ids, err := a.redis.SetMembers(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(dto.Sessions, 0, len(ids))
+	for _, id := range ids {
+		session, err := a.GetSession(id)
+		if err != nil {
+			if !errors.Is(err, errors.RedisKeyNoExist) {
+				return nil, err
+			}
+
+			// drop the index of the expired session
+			if err := a.redis.SetRemove(key, id); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	sort.Sort(sessions)
+	return sessions, nil
+}
+
+// DestroySession revokes a session with all the tokens issued for it
+func (a AuthService) DestroySession(id string) error {
+	session, err := a.GetSession(id)
+	if err != nil {
+		if errors.Is(err, errors.RedisKeyNoExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	if _, err = a.redis.Delete(wrapperSessionKey(id), wrapperSessionSeenKey(id)); err != nil {
+		return err
+	}
+
+	return a.redis.SetRemove(wrapperUserSessionsKey(session.UserID), id)
+}
+
+// DestroyOtherSessions revokes the sessions of the user except the given one
+func (a AuthService) DestroyOtherSessions(userID, sessionID string) error {
+	ids, err := a.redis.SetMembers(wrapperUserSessionsKey(userID))
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if id == sessionID {
+			continue
+		}
+
+		if err := a.DestroySession(id); err != nil {
+			return err
 		}
 	}
 
-	return nil, errors.AuthTokenInvalid
+	return nil
 }
 
-func (a AuthService) DestroyToken(username string) error {
-	_, err := a.redis.Delete(wrapperAuthKey(username))
+// DestroyUserSessions revokes all the sessions of the user
+func (a AuthService) DestroyUserSessions(userID string) error {
+	key := wrapperUserSessionsKey(userID)
+
+	ids, err := a.redis.SetMembers(key)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, 2*len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, wrapperSessionKey(id), wrapperSessionSeenKey(id))
+	}
+
+	_, err = a.redis.Delete(append(keys, key)...)
 	return err
 }
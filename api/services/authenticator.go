@@ -0,0 +1,88 @@
+package services
+
+import (
+	"time"
+
+	"github.com/RealLiuSha/echo-admin/constants"
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/pkg/ldapx"
+)
+
+// Identity is a user verified by an authenticator
+type Identity struct {
+	Username string
+	Realname string
+	Email    string
+	Phone    string
+	// groups of the user in the identity source, mapped to roles by the config
+	Groups []string
+}
+
+// Authenticator verifies the passwords of users that are kept outside of the database
+type Authenticator interface {
+	// Source is stored with the users provisioned from the authenticator
+	Source() string
+	// Authenticate returns errors.UserRecordNotFound or errors.UserInvalidPassword
+	// when the credentials are refused
+	Authenticate(username, password string) (*Identity, error)
+}
+
+// newAuthenticator returns the configured external authenticator, nil when there is none
+func newAuthenticator(config lib.Config) Authenticator {
+	conf := config.LDAP
+	if conf == nil || !conf.Enable {
+		return nil
+	}
+
+	return LDAPAuthenticator{
+		client: ldapx.New(ldapx.Config{
+			URL:                conf.URL,
+			StartTLS:           conf.StartTLS,
+			InsecureSkipVerify: conf.InsecureSkipVerify,
+			Timeout:            time.Duration(conf.Timeout) * time.Second,
+			BindDN:             conf.BindDN,
+			BindPassword:       conf.BindPassword,
+			BaseDN:             conf.BaseDN,
+			UserFilter:         conf.UserFilter,
+			UsernameAttribute:  conf.UsernameAttribute,
+			RealnameAttribute:  conf.RealnameAttribute,
+			EmailAttribute:     conf.EmailAttribute,
+			PhoneAttribute:     conf.PhoneAttribute,
+			GroupAttribute:     conf.GroupAttribute,
+			GroupBaseDN:        conf.GroupBaseDN,
+			GroupFilter:        conf.GroupFilter,
+		}),
+	}
+}
+
+// LDAPAuthenticator binds as the user to verify the password
+type LDAPAuthenticator struct {
+	client *ldapx.Client
+}
+
+func (a LDAPAuthenticator) Source() string {
+	return constants.UserSourceLDAP
+}
+
+func (a LDAPAuthenticator) Authenticate(username, password string) (*Identity, error) {
+	entry, err := a.client.Authenticate(username, password)
+	if err != nil {
+		switch err {
+		case ldapx.ErrUserNotFound, ldapx.ErrUserNotUnique:
+			return nil, errors.UserRecordNotFound
+		case ldapx.ErrInvalidCredentials:
+			return nil, errors.UserInvalidPassword
+		}
+
+		return nil, errors.Wrap(err, "ldap authentication failed")
+	}
+
+	return &Identity{
+		Username: entry.Username,
+		Realname: entry.Realname,
+		Email:    entry.Email,
+		Phone:    entry.Phone,
+		Groups:   entry.Groups,
+	}, nil
+}
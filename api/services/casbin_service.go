@@ -1,8 +1,11 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/RealLiuSha/echo-admin/models/dto"
@@ -10,9 +13,12 @@ import (
 	"github.com/casbin/casbin/v2"
 	casbinModel "github.com/casbin/casbin/v2/model"
 	"github.com/casbin/casbin/v2/persist"
+	"go.uber.org/fx"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 
 	"github.com/RealLiuSha/echo-admin/api/repository"
+	"github.com/RealLiuSha/echo-admin/errors"
 	"github.com/RealLiuSha/echo-admin/lib"
 	"github.com/RealLiuSha/echo-admin/models"
 )
@@ -23,6 +29,7 @@ type CasbinAdapter struct {
 	userRoleRepository           repository.UserRoleRepository
 	roleRepository               repository.RoleRepository
 	roleMenuRepository           repository.RoleMenuRepository
+	roleInheritRepository        repository.RoleInheritRepository
 	menuActionResourceRepository repository.MenuActionResourceRepository
 }
 
@@ -33,18 +40,45 @@ type CasbinLogger struct {
 
 // CasbinService service layer
 type CasbinService struct {
-	Enforcer *casbin.SyncedEnforcer
+	Enforcer  *casbin.SyncedEnforcer
+	logger    lib.Logger
+	adapter   *CasbinAdapter
+	watcher   *CasbinWatcher
+	redis     lib.Redis
+	trxHandle *gorm.DB
+	// changes are applied one at a time, they diff against the current policy
+	mu *sync.Mutex
+}
+
+// the version of the policy counts its changes, the caches derived from the policy are keyed by it
+const (
+	casbinPolicyVersionKey     = "casbin:version"
+	casbinPolicyVersionExpired = 30 * 24 * time.Hour
+)
+
+// casbinPolicyChange is the whole policy of the changed roles and users,
+// it replaces what an instance holds for them, so that applying it twice does no harm
+type casbinPolicyChange struct {
+	// p rules by role id, none when the role is disabled or deleted
+	Roles map[string][][]string `json:"roles,omitempty"`
+	// g2 rules of the inherited roles by role id
+	Inherits map[string][][]string `json:"inherits,omitempty"`
+	// g rules by user id
+	Users map[string][][]string `json:"users,omitempty"`
 }
 
 // NewCasbinService creates a new userservice
 func NewCasbinService(
+	lifecycle fx.Lifecycle,
 	logger lib.Logger,
 	config lib.Config,
+	redis lib.Redis,
 
 	userRepository repository.UserRepository,
 	userRoleRepository repository.UserRoleRepository,
 	roleRepository repository.RoleRepository,
 	roleMenuRepository repository.RoleMenuRepository,
+	roleInheritRepository repository.RoleInheritRepository,
 	menuActionResourceRepository repository.MenuActionResourceRepository,
 ) CasbinService {
 	adapter := &CasbinAdapter{
@@ -53,6 +87,7 @@ func NewCasbinService(
 		userRoleRepository:           userRoleRepository,
 		roleRepository:               roleRepository,
 		roleMenuRepository:           roleMenuRepository,
+		roleInheritRepository:        roleInheritRepository,
 		menuActionResourceRepository: menuActionResourceRepository,
 	}
 
@@ -64,7 +99,32 @@ func NewCasbinService(
 		logger.Zap.Fatalf("error to new casbin enforcer: %v", err)
 	}
 
+	// the super admin of the config bypasses the policy, see isSuperAdmin in the model
+	enforcer.AddFunction("isSuperAdmin", func(args ...interface{}) (interface{}, error) {
+		admin := config.SuperAdmin
+		if admin == nil || !admin.Enable || admin.Username == "" || len(args) != 1 {
+			return false, nil
+		}
+
+		sub, _ := args[0].(string)
+		return sub == admin.Username, nil
+	})
+
+	// the roles of a user hold the policies of the roles they inherit, see hasInheritedRole in the model
+	enforcer.AddFunction("hasInheritedRole", func(args ...interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return false, nil
+		}
+
+		sub, _ := args[0].(string)
+		role, _ := args[1].(string)
+		return hasInheritedRole(enforcer, sub, role)
+	})
+
 	enforcer.EnableEnforce(true)
+	// the database is the storage of the policy, changes are announced by the service
+	enforcer.EnableAutoSave(false)
+	enforcer.EnableAutoNotifyWatcher(false)
 	enforcer.EnableLog(config.Casbin.Debug)
 	enforcer.SetLogger(&CasbinLogger{
 		zap:     logger.DesugarZap.With(zap.String("module", "casbin")),
@@ -73,6 +133,10 @@ func NewCasbinService(
 
 	service := CasbinService{
 		Enforcer: enforcer,
+		logger:   logger,
+		adapter:  adapter,
+		redis:    redis,
+		mu:       new(sync.Mutex),
 	}
 
 	err = enforcer.InitWithModelAndAdapter(enforcer.GetModel(), adapter)
@@ -80,6 +144,25 @@ func NewCasbinService(
 		logger.Zap.Fatalf("error to init model and adapter: %v", err)
 	}
 
+	if config.Casbin.Watcher {
+		watcher, err := NewCasbinWatcher(logger, redis)
+		if err != nil {
+			logger.Zap.Fatalf("error to start casbin watcher: %v", err)
+		}
+
+		if err := watcher.SetUpdateCallback(service.onUpdate); err != nil {
+			logger.Zap.Fatalf("error to set casbin watcher: %v", err)
+		}
+
+		service.watcher = watcher
+		lifecycle.Append(fx.Hook{
+			OnStop: func(context.Context) error {
+				watcher.Close()
+				return nil
+			},
+		})
+	}
+
 	if config.Casbin.AutoLoad {
 		enforcer.StartAutoLoadPolicy(time.Duration(config.Casbin.AutoLoadInternal) * time.Second)
 	}
@@ -87,6 +170,244 @@ func NewCasbinService(
 	return service
 }
 
+// hasInheritedRole tells whether one of the roles of g the subject is linked to inherits the role through g2
+func hasInheritedRole(enforcer *casbin.SyncedEnforcer, sub, role string) (bool, error) {
+	assertion, ok := enforcer.GetModel()["g"]["g2"]
+	if !ok || assertion.RM == nil {
+		return false, nil
+	}
+
+	roles, err := enforcer.GetRoleManager().GetRoles(sub)
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range roles {
+		if ok, err := assertion.RM.HasLink(r, role); err != nil {
+			return false, err
+		} else if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// WithTrx defers the policy reloads until the transaction commits
+func (a CasbinService) WithTrx(trxHandle *gorm.DB) CasbinService {
+	a.trxHandle = trxHandle
+	return a
+}
+
+// UpdateRoles replaces the rules and inherited roles of the roles once the changes are committed,
+// and sends them to the other instances
+func (a CasbinService) UpdateRoles(ids ...string) {
+	a.update(func(change *casbinPolicyChange) error {
+		return a.loadRoles(change, ids)
+	})
+}
+
+// UpdateUsers replaces the roles of the users once the changes are committed,
+// and sends them to the other instances
+func (a CasbinService) UpdateUsers(ids ...string) {
+	a.update(func(change *casbinPolicyChange) error {
+		for _, id := range ids {
+			rules, err := a.adapter.userPolicy(id)
+			if err != nil {
+				return err
+			}
+
+			change.Users[id] = rules
+		}
+
+		return nil
+	})
+}
+
+// UpdateMenu replaces the rules of the roles granted an action of the menu
+func (a CasbinService) UpdateMenu(menuID string) {
+	a.update(func(change *casbinPolicyChange) error {
+		roleMenuQR, err := a.adapter.roleMenuRepository.Query(&models.RoleMenuQueryParam{MenuID: menuID})
+		if err != nil {
+			return err
+		}
+
+		return a.loadRoles(change, roleMenuQR.List.ToRoleIDs())
+	})
+}
+
+func (a CasbinService) loadRoles(change *casbinPolicyChange, ids []string) error {
+	for _, id := range ids {
+		rules, inherits, err := a.adapter.rolePolicy(id)
+		if err != nil {
+			return err
+		}
+
+		change.Roles[id] = rules
+		change.Inherits[id] = inherits
+	}
+
+	return nil
+}
+
+func (a CasbinService) update(load func(change *casbinPolicyChange) error) {
+	lib.AfterCommit(a.trxHandle, func() {
+		if _, err := a.redis.Incr(casbinPolicyVersionKey, casbinPolicyVersionExpired); err != nil {
+			a.logger.Zap.Errorf("Error to count the casbin policy version: %v", err)
+		}
+
+		change := &casbinPolicyChange{
+			Roles:    make(map[string][][]string),
+			Inherits: make(map[string][][]string),
+			Users:    make(map[string][][]string),
+		}
+
+		// the periodic reload catches up when the change cannot be loaded
+		if err := load(change); err != nil {
+			a.logger.Zap.Errorf("Error to load the changed casbin policy: %v", err)
+			return
+		}
+
+		if err := a.apply(change); err != nil {
+			a.logger.Zap.Errorf("Error to apply the changed casbin policy: %v", err)
+		}
+
+		if a.watcher == nil {
+			return
+		}
+
+		if err := a.watcher.Publish(change); err != nil {
+			a.logger.Zap.Errorf("Error to notify the casbin policy change: %v", err)
+		}
+	})
+}
+
+// apply adds the missing rules of the change and removes the rules it no longer holds
+func (a CasbinService) apply(change *casbinPolicyChange) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for id, rules := range change.Roles {
+		add, remove := diffRules(a.Enforcer.GetFilteredPolicy(0, id), rules)
+		if len(remove) > 0 {
+			if _, err := a.Enforcer.RemovePolicies(remove); err != nil {
+				return err
+			}
+		}
+
+		if len(add) > 0 {
+			if _, err := a.Enforcer.AddPolicies(add); err != nil {
+				return err
+			}
+		}
+	}
+
+	for ptype, grouping := range map[string]map[string][][]string{"g": change.Users, "g2": change.Inherits} {
+		for id, rules := range grouping {
+			add, remove := diffRules(a.Enforcer.GetFilteredNamedGroupingPolicy(ptype, 0, id), rules)
+			if len(remove) > 0 {
+				if _, err := a.Enforcer.RemoveNamedGroupingPolicies(ptype, remove); err != nil {
+					return err
+				}
+			}
+
+			if len(add) > 0 {
+				if _, err := a.Enforcer.AddNamedGroupingPolicies(ptype, add); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetRolesForUser returns the roles assigned to the user and the roles they inherit
+func (a CasbinService) GetRolesForUser(userID string) (assigned, inherited []string, err error) {
+	if assigned, err = a.Enforcer.GetRolesForUser(userID); err != nil {
+		return nil, nil, err
+	}
+
+	// the implicit roles follow both g and g2
+	roles, err := a.Enforcer.GetImplicitRolesForUser(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mAssigned := make(map[string]struct{}, len(assigned))
+	for _, role := range assigned {
+		mAssigned[role] = struct{}{}
+	}
+
+	inherited = make([]string, 0, len(roles))
+	for _, role := range roles {
+		if _, ok := mAssigned[role]; !ok {
+			inherited = append(inherited, role)
+		}
+	}
+
+	return assigned, inherited, nil
+}
+
+// PolicyVersion returns the version of the policy, it changes with every change of roles, users and menus
+func (a CasbinService) PolicyVersion() (int64, error) {
+	return a.redis.Count(casbinPolicyVersionKey)
+}
+
+// onUpdate applies the change of another instance, a message without a change reloads the whole policy
+func (a CasbinService) onUpdate(payload string) {
+	message := new(casbinWatcherMessage)
+	if err := json.Unmarshal([]byte(payload), message); err != nil {
+		a.logger.Zap.Errorf("Error to decode the casbin policy change: %v", err)
+		return
+	}
+
+	if message.Change == nil {
+		if err := a.Enforcer.LoadPolicy(); err != nil {
+			a.logger.Zap.Errorf("Error to reload the casbin policy: %v", err)
+		}
+
+		return
+	}
+
+	if err := a.apply(message.Change); err != nil {
+		a.logger.Zap.Errorf("Error to apply the casbin policy change: %v", err)
+	}
+}
+
+// diffRules returns the rules missing from the current ones and the current rules that are gone
+func diffRules(current, rules [][]string) (add, remove [][]string) {
+	key := func(rule []string) string {
+		return strings.Join(rule, "\x00")
+	}
+
+	mCurrent := make(map[string]struct{}, len(current))
+	for _, rule := range current {
+		mCurrent[key(rule)] = struct{}{}
+	}
+
+	mRules := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		k := key(rule)
+		if _, ok := mRules[k]; ok {
+			continue
+		}
+
+		mRules[k] = struct{}{}
+		if _, ok := mCurrent[k]; !ok {
+			add = append(add, rule)
+		}
+	}
+
+	for _, rule := range current {
+		if _, ok := mRules[key(rule)]; !ok {
+			remove = append(remove, rule)
+		}
+	}
+
+	return add, remove
+}
+
 // LoadPolicy loads all policy rules from the storage.
 func (a CasbinAdapter) LoadPolicy(model casbinModel.Model) error {
 	err := a.loadRolePolicy(model)
@@ -104,7 +425,7 @@ func (a CasbinAdapter) LoadPolicy(model casbinModel.Model) error {
 	return nil
 }
 
-// load role policy (p,role_id,path,method)
+// load role policy (p,role_id,path,method) and role inheritance (g2,role_id,inherit_id)
 func (a CasbinAdapter) loadRolePolicy(m casbinModel.Model) error {
 	paginationParam := dto.PaginationParam{PageSize: 9999, Current: 1}
 	roleQR, err := a.roleRepository.Query(&models.RoleQueryParam{
@@ -138,35 +459,117 @@ func (a CasbinAdapter) loadRolePolicy(m casbinModel.Model) error {
 	mMenuResources := menuResourceQR.List.ToActionIDMap()
 
 	for _, role := range roleQR.List {
-		mcache := make(map[string]struct{})
 		roleMenus, ok := mRoleMenus[role.ID]
 		if !ok {
 			continue
 		}
 
-		for _, actionID := range roleMenus.ToActionIDs() {
-			mrs, ok := mMenuResources[actionID]
-			if !ok {
-				continue
-			}
+		for _, rule := range rolePolicies(role.ID, roleMenus, mMenuResources) {
+			line := fmt.Sprintf("p,%s,%s,%s", rule[0], rule[1], rule[2])
+			persist.LoadPolicyLine(line, m)
+		}
+	}
 
-			for _, mr := range mrs {
-				if mr.Path == "" || mr.Method == "" {
-					continue
-				} else if _, ok := mcache[mr.Path+mr.Method]; ok {
-					continue
-				}
+	// the inherited roles are linked by g2, so that they stay apart from the roles of the users
+	roleInheritQR, err := a.roleInheritRepository.Query(&models.RoleInheritQueryParam{})
+	if err != nil {
+		return err
+	}
 
-				mcache[mr.Path+mr.Method] = struct{}{}
-				line := fmt.Sprintf("p,%s,%s,%s", role.ID, mr.Path, mr.Method)
-				persist.LoadPolicyLine(line, m)
-			}
+	mRoleInherits := roleInheritQR.List.ToRoleIDMap()
+	for _, role := range roleQR.List {
+		for _, ri := range mRoleInherits[role.ID] {
+			line := fmt.Sprintf("g2,%s,%s", ri.RoleID, ri.InheritID)
+			persist.LoadPolicyLine(line, m)
 		}
 	}
 
 	return nil
 }
 
+// rolePolicies returns the rules (role_id, path, method) of the resources of the actions granted to the role
+func rolePolicies(roleID string, roleMenus models.RoleMenus, mMenuResources map[string]models.MenuActionResources) [][]string {
+	var rules [][]string
+
+	mcache := make(map[string]struct{})
+	for _, actionID := range roleMenus.ToActionIDs() {
+		for _, mr := range mMenuResources[actionID] {
+			if mr.Path == "" || mr.Method == "" {
+				continue
+			} else if _, ok := mcache[mr.Path+mr.Method]; ok {
+				continue
+			}
+
+			mcache[mr.Path+mr.Method] = struct{}{}
+			rules = append(rules, []string{roleID, mr.Path, mr.Method})
+		}
+	}
+
+	return rules
+}
+
+// rolePolicy returns the rules and the inherited roles of a role, none when it is disabled or deleted
+func (a CasbinAdapter) rolePolicy(roleID string) (rules, inherits [][]string, err error) {
+	role, err := a.roleRepository.Get(roleID)
+	if errors.Is(err, errors.DatabaseRecordNotFound) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	} else if role.Status != 1 {
+		return nil, nil, nil
+	}
+
+	roleInheritQR, err := a.roleInheritRepository.Query(&models.RoleInheritQueryParam{RoleID: roleID})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, ri := range roleInheritQR.List {
+		inherits = append(inherits, []string{ri.RoleID, ri.InheritID})
+	}
+
+	roleMenuQR, err := a.roleMenuRepository.Query(&models.RoleMenuQueryParam{RoleID: roleID})
+	if err != nil {
+		return nil, nil, err
+	} else if len(roleMenuQR.List) == 0 {
+		return nil, inherits, nil
+	}
+
+	menuResourceQR, err := a.menuActionResourceRepository.Query(&models.MenuActionResourceQueryParam{
+		MenuIDs: roleMenuQR.List.ToMenuIDs(),
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rolePolicies(roleID, roleMenuQR.List, menuResourceQR.List.ToActionIDMap()), inherits, nil
+}
+
+// userPolicy returns the rules (user_id, role_id) of a user, none when it is disabled or deleted
+func (a CasbinAdapter) userPolicy(userID string) ([][]string, error) {
+	user, err := a.userRepository.Get(userID)
+	if errors.Is(err, errors.DatabaseRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if user.Status != 1 {
+		return nil, nil
+	}
+
+	userRoleQR, err := a.userRoleRepository.Query(&models.UserRoleQueryParam{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([][]string, 0, len(userRoleQR.List))
+	for _, ur := range userRoleQR.List {
+		rules = append(rules, []string{ur.UserID, ur.RoleID})
+	}
+
+	return rules, nil
+}
+
 // load user policy (g,user_id,role_id)
 func (a CasbinAdapter) loadUserPolicy(m casbinModel.Model) error {
 	paginationParam := dto.PaginationParam{PageSize: 9999, Current: 1}
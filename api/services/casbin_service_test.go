@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		current [][]string
+		rules   [][]string
+		add     [][]string
+		remove  [][]string
+	}{
+		{"no op", [][]string{{"r1", "/a", "GET"}}, [][]string{{"r1", "/a", "GET"}}, nil, nil},
+		{"add", nil, [][]string{{"r1", "/a", "GET"}}, [][]string{{"r1", "/a", "GET"}}, nil},
+		{"remove", [][]string{{"r1", "/a", "GET"}}, nil, nil, [][]string{{"r1", "/a", "GET"}}},
+		{
+			"replace",
+			[][]string{{"r1", "/a", "GET"}, {"r1", "/b", "GET"}},
+			[][]string{{"r1", "/b", "GET"}, {"r1", "/c", "POST"}},
+			[][]string{{"r1", "/c", "POST"}},
+			[][]string{{"r1", "/a", "GET"}},
+		},
+		{"duplicate rules are added once", nil, [][]string{{"u1", "r1"}, {"u1", "r1"}}, [][]string{{"u1", "r1"}}, nil},
+		// the fields are compared one by one, not as a joined string
+		{"fields", [][]string{{"r1", "/a,GET"}}, [][]string{{"r1,/a", "GET"}}, [][]string{{"r1,/a", "GET"}}, [][]string{{"r1", "/a,GET"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			add, remove := diffRules(test.current, test.rules)
+			assert.Equal(t, test.add, add)
+			assert.Equal(t, test.remove, remove)
+		})
+	}
+}
+
+// the policy of a role at the end of the longest inheritance chain reaches the user
+func TestHasInheritedRole(t *testing.T) {
+	enforcer, err := casbin.NewSyncedEnforcer("../../config/casbin_model.conf")
+	assert.NoError(t, err)
+
+	enforcer.AddFunction("isSuperAdmin", func(args ...interface{}) (interface{}, error) {
+		return false, nil
+	})
+	enforcer.AddFunction("hasInheritedRole", func(args ...interface{}) (interface{}, error) {
+		return hasInheritedRole(enforcer, args[0].(string), args[1].(string))
+	})
+
+	last := fmt.Sprintf("r%d", maxRoleInheritDepth)
+	_, err = enforcer.AddPolicy(last, "/api/v1/users/:id", "GET")
+	assert.NoError(t, err)
+	_, err = enforcer.AddNamedGroupingPolicy("g", "u1", "r0")
+	assert.NoError(t, err)
+
+	for i := 0; i < maxRoleInheritDepth; i++ {
+		_, err = enforcer.AddNamedGroupingPolicy("g2", fmt.Sprintf("r%d", i), fmt.Sprintf("r%d", i+1))
+		assert.NoError(t, err)
+	}
+
+	allowed, err := enforcer.Enforce("u1", "/api/v1/users/1", "GET")
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+
+	// an inherited role is not assigned to the user
+	roles, err := enforcer.GetRolesForUser("u1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"r0"}, roles)
+
+	allowed, err = enforcer.Enforce("u2", "/api/v1/users/1", "GET")
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+}
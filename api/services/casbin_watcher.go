@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/pkg/uuid"
+)
+
+// instances announce policy changes on the channel
+const casbinWatcherChannel = "casbin:policy"
+
+// CasbinWatcher is a casbin persist.Watcher on redis pub/sub,
+// it sends the policy changes to the other instances
+type CasbinWatcher struct {
+	logger lib.Logger
+	redis  lib.Redis
+	// messages of this instance are skipped, its policy is already up to date
+	instance string
+	pubsub   *redis.PubSub
+
+	mu       sync.RWMutex
+	callback func(string)
+}
+
+type casbinWatcherMessage struct {
+	Instance string `json:"instance"`
+	// the other instances reload the whole policy when there is no change
+	Change *casbinPolicyChange `json:"change,omitempty"`
+}
+
+// NewCasbinWatcher subscribes to the policy changes of the other instances
+func NewCasbinWatcher(logger lib.Logger, redis lib.Redis) (*CasbinWatcher, error) {
+	pubsub := redis.Subscribe(casbinWatcherChannel)
+
+	// changes published before the subscription is confirmed would be missed
+	if _, err := pubsub.Receive(context.TODO()); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	watcher := &CasbinWatcher{
+		logger:   logger,
+		redis:    redis,
+		instance: uuid.MustString(),
+		pubsub:   pubsub,
+	}
+
+	go watcher.listen()
+	return watcher, nil
+}
+
+func (a *CasbinWatcher) listen() {
+	for msg := range a.pubsub.Channel() {
+		message := new(casbinWatcherMessage)
+		if err := json.Unmarshal([]byte(msg.Payload), message); err != nil {
+			a.logger.Zap.Errorf("Error to decode the casbin policy change %q: %v", msg.Payload, err)
+			continue
+		} else if message.Instance == a.instance {
+			continue
+		}
+
+		a.mu.RLock()
+		callback := a.callback
+		a.mu.RUnlock()
+
+		if callback != nil {
+			a.logger.Zap.Debugf("casbin policy changed by instance %s", message.Instance)
+			callback(msg.Payload)
+		}
+	}
+}
+
+// SetUpdateCallback sets the function called when another instance changes the policy
+func (a *CasbinWatcher) SetUpdateCallback(callback func(string)) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.callback = callback
+	return nil
+}
+
+// Update tells the other instances to reload the whole policy
+func (a *CasbinWatcher) Update() error {
+	return a.Publish(nil)
+}
+
+// Publish sends the change to the other instances
+func (a *CasbinWatcher) Publish(change *casbinPolicyChange) error {
+	payload, err := json.Marshal(&casbinWatcherMessage{Instance: a.instance, Change: change})
+	if err != nil {
+		return err
+	}
+
+	return a.redis.Publish(casbinWatcherChannel, payload)
+}
+
+// Close stops listening to the changes
+func (a *CasbinWatcher) Close() {
+	if err := a.pubsub.Close(); err != nil {
+		a.logger.Zap.Errorf("Error to close the casbin watcher: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"github.com/RealLiuSha/echo-admin/api/repository"
+	"github.com/RealLiuSha/echo-admin/constants"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+)
+
+// DataScopeService resolves which rows the roles of a user reach
+type DataScopeService struct {
+	logger                   lib.Logger
+	userService              UserService
+	departmentService        DepartmentService
+	userRoleRepository       repository.UserRoleRepository
+	userDepartmentRepository repository.UserDepartmentRepository
+	roleRepository           repository.RoleRepository
+	roleDepartmentRepository repository.RoleDepartmentRepository
+}
+
+// NewDataScopeService creates a new data scope service
+func NewDataScopeService(
+	logger lib.Logger,
+	userService UserService,
+	departmentService DepartmentService,
+	userRoleRepository repository.UserRoleRepository,
+	userDepartmentRepository repository.UserDepartmentRepository,
+	roleRepository repository.RoleRepository,
+	roleDepartmentRepository repository.RoleDepartmentRepository,
+) DataScopeService {
+	return DataScopeService{
+		logger:                   logger,
+		userService:              userService,
+		departmentService:        departmentService,
+		userRoleRepository:       userRoleRepository,
+		userDepartmentRepository: userDepartmentRepository,
+		roleRepository:           roleRepository,
+		roleDepartmentRepository: roleDepartmentRepository,
+	}
+}
+
+// Get returns the data scope of the user, the scopes of its roles add up and nil reaches all rows,
+// a user always reaches its own rows
+func (a DataScopeService) Get(userID string) (*lib.DataScope, error) {
+	if a.userService.IsSuperAdmin(userID) {
+		return nil, nil
+	}
+
+	scope := &lib.DataScope{UserID: userID}
+
+	userRoleQR, err := a.userRoleRepository.Query(&models.UserRoleQueryParam{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	roleIDs, err := a.userService.EffectiveRoleIDs(userRoleQR.List.ToRoleIDs())
+	if err != nil {
+		return nil, err
+	} else if len(roleIDs) == 0 {
+		return scope, nil
+	}
+
+	roleQR, err := a.roleRepository.Query(&models.RoleQueryParam{IDs: roleIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	var own, children bool
+	var customRoleIDs []string
+	for _, role := range roleQR.List {
+		switch role.DataScope {
+		case constants.DataScopeAll, "":
+			return nil, nil
+		case constants.DataScopeDepartment:
+			own = true
+		case constants.DataScopeDepartmentAndChildren:
+			own, children = true, true
+		case constants.DataScopeCustom:
+			customRoleIDs = append(customRoleIDs, role.ID)
+		}
+	}
+
+	var departmentIDs []string
+	if own {
+		userDepartmentQR, err := a.userDepartmentRepository.Query(&models.UserDepartmentQueryParam{UserID: userID})
+		if err != nil {
+			return nil, err
+		}
+
+		departmentIDs = userDepartmentQR.List.ToDepartmentIDs()
+		if children {
+			if departmentIDs, err = a.departmentService.SubtreeIDs(departmentIDs...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(customRoleIDs) > 0 {
+		roleDepartmentQR, err := a.roleDepartmentRepository.Query(&models.RoleDepartmentQueryParam{RoleIDs: customRoleIDs})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, roleDepartment := range roleDepartmentQR.List {
+			departmentIDs = append(departmentIDs, roleDepartment.DepartmentID)
+		}
+	}
+
+	seen := make(map[string]struct{})
+	for _, id := range departmentIDs {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			scope.DepartmentIDs = append(scope.DepartmentIDs, id)
+		}
+	}
+
+	return scope, nil
+}
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/api/repository"
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/pkg/uuid"
+)
+
+// DepartmentService service layer
+type DepartmentService struct {
+	logger                     lib.Logger
+	departmentRepository       repository.DepartmentRepository
+	departmentLeaderRepository repository.DepartmentLeaderRepository
+	userDepartmentRepository   repository.UserDepartmentRepository
+	roleDepartmentRepository   repository.RoleDepartmentRepository
+	userRepository             repository.UserRepository
+}
+
+// NewDepartmentService creates a new department service
+func NewDepartmentService(
+	logger lib.Logger,
+	departmentRepository repository.DepartmentRepository,
+	departmentLeaderRepository repository.DepartmentLeaderRepository,
+	userDepartmentRepository repository.UserDepartmentRepository,
+	roleDepartmentRepository repository.RoleDepartmentRepository,
+	userRepository repository.UserRepository,
+) DepartmentService {
+	return DepartmentService{
+		logger:                     logger,
+		departmentRepository:       departmentRepository,
+		departmentLeaderRepository: departmentLeaderRepository,
+		userDepartmentRepository:   userDepartmentRepository,
+		roleDepartmentRepository:   roleDepartmentRepository,
+		userRepository:             userRepository,
+	}
+}
+
+// WithTrx delegates transaction to repository database
+func (a DepartmentService) WithTrx(trxHandle *gorm.DB) DepartmentService {
+	a.departmentRepository = a.departmentRepository.WithTrx(trxHandle)
+	a.departmentLeaderRepository = a.departmentLeaderRepository.WithTrx(trxHandle)
+	a.userDepartmentRepository = a.userDepartmentRepository.WithTrx(trxHandle)
+	a.roleDepartmentRepository = a.roleDepartmentRepository.WithTrx(trxHandle)
+	a.userRepository = a.userRepository.WithTrx(trxHandle)
+
+	return a
+}
+
+func (a DepartmentService) Check(item *models.Department) error {
+	result, err := a.departmentRepository.Query(&models.DepartmentQueryParam{
+		Name:     item.Name,
+		ParentID: item.ParentID,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, department := range result.List {
+		// the name only has to be unique among the siblings
+		if department.ParentID == item.ParentID {
+			return errors.DepartmentAlreadyExists
+		}
+	}
+
+	return nil
+}
+
+// CheckLeaders refuses leaders that are not users
+func (a DepartmentService) CheckLeaders(leaders models.DepartmentLeaders) error {
+	for userID := range leaders.ToMap() {
+		if _, err := a.userRepository.Get(userID); errors.Is(err, errors.DatabaseRecordNotFound) {
+			return errors.DepartmentInvalidLeader
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a DepartmentService) Query(param *models.DepartmentQueryParam) (*models.DepartmentQueryResult, error) {
+	departmentQR, err := a.departmentRepository.Query(param)
+	if err != nil {
+		return nil, err
+	} else if len(departmentQR.List) == 0 {
+		return departmentQR, nil
+	}
+
+	leaderQR, err := a.departmentLeaderRepository.Query(&models.DepartmentLeaderQueryParam{
+		DepartmentIDs: departmentQR.List.ToIDs(),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	m := leaderQR.List.ToDepartmentIDMap()
+	for _, department := range departmentQR.List {
+		department.Leaders = m[department.ID]
+	}
+
+	return departmentQR, nil
+}
+
+func (a DepartmentService) Get(id string) (*models.Department, error) {
+	department, err := a.departmentRepository.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	leaderQR, err := a.departmentLeaderRepository.Query(&models.DepartmentLeaderQueryParam{
+		DepartmentID: id,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	department.Leaders = leaderQR.List
+	return department, nil
+}
+
+func (a DepartmentService) Create(department *models.Department) (id string, err error) {
+	if err = a.Check(department); err != nil {
+		return
+	}
+
+	if err = a.CheckLeaders(department.Leaders); err != nil {
+		return
+	}
+
+	if department.ParentPath, err = a.GetParentPath(department.ParentID); err != nil {
+		return
+	}
+
+	department.ID = uuid.MustString()
+	for _, leader := range department.Leaders.ToMap() {
+		leader.ID = uuid.MustString()
+		leader.DepartmentID = department.ID
+
+		if err = a.departmentLeaderRepository.Create(leader); err != nil {
+			return
+		}
+	}
+
+	if err = a.departmentRepository.Create(department); err != nil {
+		return
+	}
+
+	return department.ID, nil
+}
+
+func (a DepartmentService) Update(id string, department *models.Department) error {
+	if id == department.ParentID {
+		return errors.DepartmentInvalidParent
+	}
+
+	oDepartment, err := a.Get(id)
+	if err != nil {
+		return err
+	} else if oDepartment.Name != department.Name || oDepartment.ParentID != department.ParentID {
+		if err = a.Check(department); err != nil {
+			return err
+		}
+	}
+
+	department.ID = oDepartment.ID
+	department.CreatedBy = oDepartment.CreatedBy
+	department.CreatedAt = oDepartment.CreatedAt
+
+	if department.ParentID != oDepartment.ParentID {
+		parentPath, err := a.GetParentPath(department.ParentID)
+		if err != nil {
+			return err
+		}
+
+		// a department cannot be moved below its own sub departments
+		for _, parentID := range strings.Split(parentPath, "/") {
+			if parentID == id {
+				return errors.DepartmentInvalidParent
+			}
+		}
+
+		department.ParentPath = parentPath
+	} else {
+		department.ParentPath = oDepartment.ParentPath
+	}
+
+	if err = a.UpdateChildParentPath(oDepartment, department); err != nil {
+		return err
+	}
+
+	if err = a.CheckLeaders(department.Leaders); err != nil {
+		return err
+	}
+
+	aLeaders, dLeaders := a.CompareLeaders(oDepartment.Leaders, department.Leaders)
+	for _, aLeader := range aLeaders {
+		aLeader.ID = uuid.MustString()
+		aLeader.DepartmentID = id
+
+		if err := a.departmentLeaderRepository.Create(aLeader); err != nil {
+			return err
+		}
+	}
+
+	for _, dLeader := range dLeaders {
+		if err := a.departmentLeaderRepository.Delete(dLeader.ID); err != nil {
+			return err
+		}
+	}
+
+	return a.departmentRepository.Update(id, department)
+}
+
+func (a DepartmentService) Delete(id string) error {
+	_, err := a.departmentRepository.Get(id)
+	if err != nil {
+		return err
+	}
+
+	departmentQR, err := a.departmentRepository.Query(&models.DepartmentQueryParam{
+		ParentID: id,
+	})
+
+	if err != nil {
+		return err
+	} else if departmentQR.Pagination.Total > 0 {
+		return errors.DepartmentNotAllowDeleteWithChild
+	}
+
+	userDepartmentQR, err := a.userDepartmentRepository.Query(&models.UserDepartmentQueryParam{
+		DepartmentIDs: []string{id},
+	})
+
+	if err != nil {
+		return err
+	} else if userDepartmentQR.Pagination.Total > 0 {
+		return errors.DepartmentNotAllowDeleteWithUser
+	}
+
+	if err = a.departmentLeaderRepository.DeleteByDepartmentID(id); err != nil {
+		return err
+	}
+
+	if err = a.roleDepartmentRepository.DeleteByDepartmentID(id); err != nil {
+		return err
+	}
+
+	return a.departmentRepository.Delete(id)
+}
+
+func (a DepartmentService) UpdateStatus(id string, status int) error {
+	_, err := a.departmentRepository.Get(id)
+	if err != nil {
+		return err
+	}
+
+	return a.departmentRepository.UpdateStatus(id, status)
+}
+
+// SubtreeIDs returns the ids of the departments and of all their sub departments
+func (a DepartmentService) SubtreeIDs(ids ...string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	departmentQR, err := a.departmentRepository.Query(&models.DepartmentQueryParam{IDs: ids})
+	if err != nil {
+		return nil, err
+	}
+
+	var subtree []string
+	seen := make(map[string]struct{})
+	for _, department := range departmentQR.List {
+		childQR, err := a.departmentRepository.Query(&models.DepartmentQueryParam{
+			PrefixParentPath: a.JoinParentPath(department.ParentPath, department.ID),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range append([]string{department.ID}, childQR.List.ToIDs()...) {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				subtree = append(subtree, id)
+			}
+		}
+	}
+
+	return subtree, nil
+}
+
+func (a DepartmentService) GetParentPath(parentID string) (string, error) {
+	if parentID == "" {
+		return "", nil
+	}
+
+	parent, err := a.departmentRepository.Get(parentID)
+	if err != nil {
+		return "", err
+	}
+
+	return a.JoinParentPath(parent.ParentPath, parent.ID), nil
+}
+
+func (a DepartmentService) JoinParentPath(parent, id string) string {
+	if parent != "" {
+		return parent + "/" + id
+	}
+
+	return id
+}
+
+func (a DepartmentService) CompareLeaders(oLeaders, nLeaders models.DepartmentLeaders) (aList, dList models.DepartmentLeaders) {
+	oMap := oLeaders.ToMap()
+	nMap := nLeaders.ToMap()
+
+	for k, item := range nMap {
+		if _, ok := oMap[k]; ok {
+			delete(oMap, k)
+			continue
+		}
+
+		aList = append(aList, item)
+	}
+
+	for _, item := range oMap {
+		dList = append(dList, item)
+	}
+
+	return
+}
+
+func (a DepartmentService) UpdateChildParentPath(oDepartment, nDepartment *models.Department) error {
+	if oDepartment.ParentID == nDepartment.ParentID {
+		return nil
+	}
+
+	oPath := a.JoinParentPath(oDepartment.ParentPath, oDepartment.ID)
+	departmentQR, err := a.departmentRepository.Query(&models.DepartmentQueryParam{
+		PrefixParentPath: oPath,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	nPath := a.JoinParentPath(nDepartment.ParentPath, nDepartment.ID)
+	for _, department := range departmentQR.List {
+		err = a.departmentRepository.UpdateParentPath(department.ID, nPath+department.ParentPath[len(oPath):])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+)
+
+// LoginGuardService counts failed logins by username and client ip,
+// to slow down and lock out password guessing
+type LoginGuardService struct {
+	logger lib.Logger
+	redis  lib.Redis
+	conf   *lib.LoginGuardConfig
+	// failed logins are also counted for the captcha when the guard is disabled
+	counting bool
+}
+
+// NewLoginGuardService creates a new login guard service
+func NewLoginGuardService(logger lib.Logger, redis lib.Redis, config lib.Config) LoginGuardService {
+	conf := config.Auth.LoginGuard
+	if conf == nil {
+		conf = &lib.LoginGuardConfig{}
+	}
+
+	if conf.LockDuration <= 0 {
+		conf.LockDuration = 900
+	}
+	if conf.Window <= 0 {
+		conf.Window = 900
+	}
+
+	return LoginGuardService{
+		logger:   logger,
+		redis:    redis,
+		conf:     conf,
+		counting: conf.Enable || (config.Captcha != nil && config.Captcha.RequireAfter > 0),
+	}
+}
+
+func wrapperLoginFailKey(kind, id string) string {
+	return fmt.Sprintf("auth:login:fail:%s:%s", kind, id)
+}
+
+func wrapperLoginLockKey(kind, id string) string {
+	return fmt.Sprintf("auth:login:lock:%s:%s", kind, id)
+}
+
+func wrapperLoginBackoffKey(username string) string {
+	return fmt.Sprintf("auth:login:backoff:%s", username)
+}
+
+// Check refuses the login while the username or the ip is locked or waiting,
+// the returned duration is how long the client should wait
+func (a LoginGuardService) Check(username, ip string) (time.Duration, error) {
+	if !a.conf.Enable {
+		return 0, nil
+	}
+
+	username = strings.ToLower(username)
+
+	if ttl, err := a.redis.TTL(wrapperLoginLockKey("user", username)); err != nil {
+		return 0, err
+	} else if ttl > 0 {
+		return ttl, errors.UserIsLocked
+	}
+
+	if ttl, err := a.redis.TTL(wrapperLoginLockKey("ip", ip)); err != nil {
+		return 0, err
+	} else if ttl > 0 {
+		return ttl, errors.AuthLoginThrottled
+	}
+
+	if ttl, err := a.redis.TTL(wrapperLoginBackoffKey(username)); err != nil {
+		return 0, err
+	} else if ttl > 0 {
+		return ttl, errors.AuthLoginThrottled
+	}
+
+	return 0, nil
+}
+
+// Fail records a failed login, and locks the username or ip or delays the next attempt
+func (a LoginGuardService) Fail(username, ip string) error {
+	if !a.counting {
+		return nil
+	}
+
+	username = strings.ToLower(username)
+	window := time.Duration(a.conf.Window) * time.Second
+
+	n, err := a.redis.Incr(wrapperLoginFailKey("user", username), window)
+	if err != nil {
+		return err
+	}
+
+	if max := a.conf.MaxAttempts; a.conf.Enable && max > 0 && n >= int64(max) {
+		a.logger.Zap.Warnf("user %s is locked after %d failed logins, the last from %s", username, n, ip)
+		if err := a.lock("user", username); err != nil {
+			return err
+		}
+	} else if after := a.conf.BackoffAfter; a.conf.Enable && after > 0 && n >= int64(after) {
+		if err := a.redis.Set(wrapperLoginBackoffKey(username), n, a.backoff(n-int64(after))); err != nil {
+			return err
+		}
+	}
+
+	n, err = a.redis.Incr(wrapperLoginFailKey("ip", ip), window)
+	if err != nil {
+		return err
+	}
+
+	if max := a.conf.IPMaxAttempts; a.conf.Enable && max > 0 && n >= int64(max) {
+		a.logger.Zap.Warnf("ip %s is locked after %d failed logins", ip, n)
+		return a.lock("ip", ip)
+	}
+
+	return nil
+}
+
+// Failures returns the failed logins counted for the username or the ip, whichever are more
+func (a LoginGuardService) Failures(username, ip string) (int64, error) {
+	if !a.counting {
+		return 0, nil
+	}
+
+	byUser, err := a.redis.Count(wrapperLoginFailKey("user", strings.ToLower(username)))
+	if err != nil {
+		return 0, err
+	}
+
+	byIP, err := a.redis.Count(wrapperLoginFailKey("ip", ip))
+	if err != nil {
+		return 0, err
+	}
+
+	if byIP > byUser {
+		return byIP, nil
+	}
+
+	return byUser, nil
+}
+
+// backoff doubles the wait with each failed login past the threshold
+func (a LoginGuardService) backoff(n int64) time.Duration {
+	base, max := a.conf.BackoffBase, a.conf.BackoffMax
+	if base <= 0 {
+		base = 1
+	}
+
+	delay := base
+	for i := int64(0); i < n && (max <= 0 || delay < max); i++ {
+		delay *= 2
+	}
+
+	if max > 0 && delay > max {
+		delay = max
+	}
+
+	return time.Duration(delay) * time.Second
+}
+
+func (a LoginGuardService) lock(kind, id string) error {
+	expired := time.Duration(a.conf.LockDuration) * time.Second
+	if err := a.redis.Set(wrapperLoginLockKey(kind, id), time.Now().Unix(), expired); err != nil {
+		return err
+	}
+
+	_, err := a.redis.Delete(wrapperLoginFailKey(kind, id))
+	return err
+}
+
+// Succeed forgets the failed logins of the username
+func (a LoginGuardService) Succeed(username string) error {
+	if !a.counting {
+		return nil
+	}
+
+	username = strings.ToLower(username)
+	_, err := a.redis.Delete(wrapperLoginFailKey("user", username), wrapperLoginBackoffKey(username))
+	return err
+}
+
+// Unlock lifts the lock of the username before it expires
+func (a LoginGuardService) Unlock(username string) error {
+	username = strings.ToLower(username)
+	_, err := a.redis.Delete(
+		wrapperLoginLockKey("user", username),
+		wrapperLoginFailKey("user", username),
+		wrapperLoginBackoffKey(username),
+	)
+
+	return err
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/api/repository"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+	"github.com/RealLiuSha/echo-admin/pkg/uuid"
+)
+
+// LoginLogService records the login attempts and the last login of the users
+type LoginLogService struct {
+	logger             lib.Logger
+	userRepository     repository.UserRepository
+	loginLogRepository repository.LoginLogRepository
+}
+
+// NewLoginLogService creates a new login log service
+func NewLoginLogService(
+	logger lib.Logger,
+	userRepository repository.UserRepository,
+	loginLogRepository repository.LoginLogRepository,
+) LoginLogService {
+	return LoginLogService{
+		logger:             logger,
+		userRepository:     userRepository,
+		loginLogRepository: loginLogRepository,
+	}
+}
+
+// WithTrx delegates transaction to repository database
+func (a LoginLogService) WithTrx(trxHandle *gorm.DB) LoginLogService {
+	a.userRepository = a.userRepository.WithTrx(trxHandle)
+	a.loginLogRepository = a.loginLogRepository.WithTrx(trxHandle)
+
+	return a
+}
+
+func (a LoginLogService) Query(param *models.LoginLogQueryParam) (*models.LoginLogQueryResult, error) {
+	return a.loginLogRepository.Query(param)
+}
+
+// Record stores a login attempt, the reason of a failure is the error, the user is nil
+// when it is not known, and the last login of the user is updated on success.
+// It must not run in the transaction of the request, which is rolled back for failed logins
+func (a LoginLogService) Record(method, username string, user *models.User, client dto.Client, err error) error {
+	log := &models.LoginLog{
+		ID:        uuid.MustString(),
+		Username:  username,
+		Method:    method,
+		IP:        client.IP,
+		UserAgent: truncate(client.UserAgent, 255),
+		Success:   err == nil,
+	}
+
+	if err != nil {
+		log.Reason = truncate(err.Error(), 255)
+	}
+
+	if user != nil {
+		log.UserID, log.Username = user.ID, user.Username
+	} else if username != "" {
+		// failed attempts are kept with the account they targeted
+		userQR, err := a.userRepository.Query(&models.UserQueryParam{Username: username})
+		if err != nil {
+			return err
+		} else if len(userQR.List) == 1 {
+			log.UserID = userQR.List[0].ID
+		}
+	}
+
+	if err := a.loginLogRepository.Create(log); err != nil {
+		return err
+	}
+
+	if log.Success && user != nil {
+		return a.userRepository.UpdateLastLogin(user.ID, database.Datetime{Time: time.Now(), Valid: true}, client.IP)
+	}
+
+	return nil
+}
+
+// truncate keeps the first size characters of a valid utf-8 string, the columns count characters
+func truncate(s string, size int) string {
+	s = strings.ToValidUTF8(s, "")
+	if utf8.RuneCountInString(s) <= size {
+		return s
+	}
+
+	return string([]rune(s)[:size])
+}
@@ -17,6 +17,7 @@ type MenuService struct {
 	menuRepository               repository.MenuRepository
 	menuActionRepository         repository.MenuActionRepository
 	menuActionResourceRepository repository.MenuActionResourceRepository
+	casbinService                CasbinService
 }
 
 // NewMenuService creates a new menu service
@@ -25,12 +26,14 @@ func NewMenuService(
 	menuRepository repository.MenuRepository,
 	menuActionRepository repository.MenuActionRepository,
 	menuActionResourceRepository repository.MenuActionResourceRepository,
+	casbinService CasbinService,
 ) MenuService {
 	return MenuService{
 		logger:                       logger,
 		menuRepository:               menuRepository,
 		menuActionRepository:         menuActionRepository,
 		menuActionResourceRepository: menuActionResourceRepository,
+		casbinService:                casbinService,
 	}
 }
 
@@ -39,6 +42,7 @@ func (a MenuService) WithTrx(trxHandle *gorm.DB) MenuService {
 	a.menuRepository = a.menuRepository.WithTrx(trxHandle)
 	a.menuActionRepository = a.menuActionRepository.WithTrx(trxHandle)
 	a.menuActionResourceRepository = a.menuActionResourceRepository.WithTrx(trxHandle)
+	a.casbinService = a.casbinService.WithTrx(trxHandle)
 
 	return a
 }
@@ -295,6 +299,8 @@ func (a MenuService) UpdateActions(menuID string, actions models.MenuActions) er
 		}
 	}
 
+	// the resources of the actions granted to roles may have changed
+	a.casbinService.UpdateMenu(menuID)
 	return nil
 }
 
@@ -326,6 +332,7 @@ func (a MenuService) Delete(id string) error {
 		return err
 	}
 
+	a.casbinService.UpdateMenu(id)
 	return nil
 }
 
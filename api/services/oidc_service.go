@@ -0,0 +1,228 @@
+package services
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/api/repository"
+	"github.com/RealLiuSha/echo-admin/constants"
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+	"github.com/RealLiuSha/echo-admin/pkg/hash"
+	"github.com/RealLiuSha/echo-admin/pkg/oidc"
+	"github.com/RealLiuSha/echo-admin/pkg/random"
+	"github.com/RealLiuSha/echo-admin/pkg/uuid"
+)
+
+// an authorization request has to come back within this time
+const oidcStateExpired = 10 * time.Minute
+
+// OIDCService signs in users at an OpenID Connect provider
+// with the authorization code flow and PKCE
+type OIDCService struct {
+	logger             lib.Logger
+	config             lib.Config
+	redis              lib.Redis
+	userService        UserService
+	identityRepository repository.UserIdentityRepository
+	provider           *oidc.Provider
+}
+
+// NewOIDCService creates a new oidc service
+func NewOIDCService(
+	logger lib.Logger,
+	config lib.Config,
+	redis lib.Redis,
+	userService UserService,
+	identityRepository repository.UserIdentityRepository,
+) OIDCService {
+	service := OIDCService{
+		logger:             logger,
+		config:             config,
+		redis:              redis,
+		userService:        userService,
+		identityRepository: identityRepository,
+	}
+
+	if conf := config.OIDC; conf != nil && conf.Enable {
+		service.provider = oidc.NewProvider(oidc.Config{
+			Issuer:       conf.Issuer,
+			ClientID:     conf.ClientID,
+			ClientSecret: conf.ClientSecret,
+			RedirectURL:  conf.RedirectURL,
+			Scopes:       conf.Scopes,
+		})
+	}
+
+	return service
+}
+
+// WithTrx delegates transaction to repository database
+func (a OIDCService) WithTrx(trxHandle *gorm.DB) OIDCService {
+	a.userService = a.userService.WithTrx(trxHandle)
+	a.identityRepository = a.identityRepository.WithTrx(trxHandle)
+	return a
+}
+
+func wrapperOIDCStateKey(state string) string {
+	return fmt.Sprintf("auth:oidc:%s", state)
+}
+
+// Login starts an authorization request and returns the url of the provider the user is sent to,
+// with the binding the browser has to present to the callback
+func (a OIDCService) Login() (authURL, binding string, err error) {
+	if a.provider == nil {
+		return "", "", errors.OIDCNotEnabled
+	}
+
+	state := random.Token(32)
+	pending := &dto.OIDCState{
+		Nonce:    random.Token(32),
+		Verifier: oidc.NewVerifier(),
+	}
+
+	if err = a.redis.Set(wrapperOIDCStateKey(state), pending, oidcStateExpired); err != nil {
+		return "", "", err
+	}
+
+	authURL, err = a.provider.AuthCodeURL(state, pending.Nonce, oidc.Challenge(pending.Verifier))
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, hash.SHA256(state), nil
+}
+
+// Callback redeems the authorization code of the provider and returns the signed in user,
+// the binding of Login has to match the state so that a login can not be completed in another browser,
+// users are linked by the subject of the id token and provisioned on their first login
+func (a OIDCService) Callback(state, binding, code string) (*models.User, error) {
+	if a.provider == nil {
+		return nil, errors.OIDCNotEnabled
+	}
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(hash.SHA256(state)), []byte(binding)) != 1 {
+		return nil, errors.OIDCStateInvalid
+	}
+
+	key := wrapperOIDCStateKey(state)
+	pending := new(dto.OIDCState)
+
+	if err := a.redis.GetSkippingLocalCache(key, pending); err != nil {
+		if errors.Is(err, errors.RedisKeyNoExist) {
+			return nil, errors.OIDCStateInvalid
+		}
+
+		return nil, err
+	}
+
+	// the state is spent by whoever deletes it first
+	if ok, err := a.redis.Delete(key); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errors.OIDCStateInvalid
+	}
+
+	token, err := a.provider.Exchange(code, pending.Verifier)
+	if err != nil {
+		return nil, errors.Wrap(err, "oidc code exchange failed")
+	}
+
+	idToken, err := a.provider.Verify(token.IDToken, pending.Nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "oidc id token verification failed")
+	}
+
+	identity, err := a.identityOf(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := idToken.String("iss")
+	user, linked, err := a.find(issuer, idToken, identity)
+	if err != nil {
+		return nil, err
+	} else if user != nil && user.ServiceAccount {
+		return nil, errors.UserIsServiceAccount
+	}
+
+	if user, err = a.userService.SignInExternal(user, constants.UserSourceOIDC, identity, a.config.OIDC.GroupRoles); err != nil {
+		return nil, err
+	}
+
+	if !linked {
+		err := a.identityRepository.Create(&models.UserIdentity{
+			ID:       uuid.MustString(),
+			UserID:   user.ID,
+			Provider: issuer,
+			Subject:  idToken.Subject,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		a.logger.Zap.Infof("user %s is linked to subject %s of %s", user.Username, idToken.Subject, issuer)
+	}
+
+	return user, nil
+}
+
+func (a OIDCService) identityOf(idToken *oidc.IDToken) (*Identity, error) {
+	conf := a.config.OIDC
+
+	usernameClaim := conf.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "preferred_username"
+	}
+
+	groupsClaim := conf.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	identity := &Identity{
+		Username: idToken.String(usernameClaim),
+		Realname: idToken.String("name"),
+		Email:    idToken.String("email"),
+		Phone:    idToken.String("phone_number"),
+		Groups:   idToken.Strings(groupsClaim),
+	}
+
+	if identity.Username == "" {
+		return nil, errors.OIDCNoUsername
+	}
+
+	return identity, nil
+}
+
+// find returns the user linked to the subject, or the user with the same verified mail address
+// when LinkByEmail is set, the user is nil when it has to be provisioned
+func (a OIDCService) find(issuer string, idToken *oidc.IDToken, identity *Identity) (*models.User, bool, error) {
+	linked, err := a.identityRepository.GetBySubject(issuer, idToken.Subject)
+	if err == nil {
+		user, err := a.userService.Get(linked.UserID)
+		return user, true, err
+	} else if !errors.Is(err, errors.DatabaseRecordNotFound) {
+		return nil, false, err
+	}
+
+	if !a.config.OIDC.LinkByEmail || identity.Email == "" || !idToken.Bool("email_verified") {
+		return nil, false, nil
+	}
+
+	userQR, err := a.userService.Query(&models.UserQueryParam{Email: identity.Email})
+	if err != nil {
+		return nil, false, err
+	} else if len(userQR.List) != 1 {
+		return nil, false, nil
+	}
+
+	user, err := a.userService.Get(userQR.List[0].ID)
+	return user, false, err
+}
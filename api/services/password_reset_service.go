@@ -0,0 +1,185 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/pkg/hash"
+	"github.com/RealLiuSha/echo-admin/pkg/random"
+)
+
+// a user gets at most one reset message per interval, in seconds
+const passwordResetInterval = 60
+
+// PasswordResetService resets forgotten passwords with single-use tokens sent to the user
+type PasswordResetService struct {
+	logger      lib.Logger
+	config      lib.Config
+	redis       lib.Redis
+	notifier    lib.Notifier
+	userService UserService
+	authService AuthService
+}
+
+// NewPasswordResetService creates a new password reset service
+func NewPasswordResetService(
+	logger lib.Logger,
+	config lib.Config,
+	redis lib.Redis,
+	notifier lib.Notifier,
+	userService UserService,
+	authService AuthService,
+) PasswordResetService {
+	return PasswordResetService{
+		logger:      logger,
+		config:      config,
+		redis:       redis,
+		notifier:    notifier,
+		userService: userService,
+		authService: authService,
+	}
+}
+
+// WithTrx delegates transaction to repository database
+func (a PasswordResetService) WithTrx(trxHandle *gorm.DB) PasswordResetService {
+	a.userService = a.userService.WithTrx(trxHandle)
+	return a
+}
+
+func wrapperResetTokenKey(id string) string {
+	return fmt.Sprintf("auth:reset:%s", id)
+}
+
+func wrapperUserResetKey(userID string) string {
+	return fmt.Sprintf("auth:reset:user:%s", userID)
+}
+
+func (a PasswordResetService) expired() time.Duration {
+	if v := a.config.Auth.ResetTokenExpired; v > 0 {
+		return time.Duration(v) * time.Second
+	}
+
+	return 30 * time.Minute
+}
+
+// Request sends a reset token to the mail address of the user,
+// unknown users are ignored so that the caller cannot tell which usernames exist
+func (a PasswordResetService) Request(username string) error {
+	user, err := a.userService.GetByUsername(username)
+	if err != nil {
+		if errors.Is(err, errors.UserRecordNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if user.Status != 1 || user.Email == "" || user.ServiceAccount || !user.IsLocal() {
+		a.logger.Zap.Warnf("password reset of user %s is ignored, the user is disabled, has no mail address or no local password", username)
+		return nil
+	}
+
+	expired := a.expired()
+	userKey := wrapperUserResetKey(user.ID)
+
+	if ttl, err := a.redis.TTL(userKey); err != nil {
+		return err
+	} else if expired-ttl < passwordResetInterval*time.Second {
+		return nil
+	}
+
+	// only the latest token of the user is valid
+	var previous string
+	if err := a.redis.GetSkippingLocalCache(userKey, &previous); err == nil {
+		if _, err := a.redis.Delete(wrapperResetTokenKey(previous)); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, errors.RedisKeyNoExist) {
+		return err
+	}
+
+	token := random.Token(32)
+	id := hash.SHA256(token)
+
+	if err := a.redis.Set(wrapperResetTokenKey(id), user.ID, expired); err != nil {
+		return err
+	}
+
+	if err := a.redis.Set(userKey, id, expired); err != nil {
+		return err
+	}
+
+	return a.notifier.Notify(a.message(user, token, expired))
+}
+
+func (a PasswordResetService) message(user *models.User, token string, expired time.Duration) *lib.NotifyMessage {
+	body := new(strings.Builder)
+
+	fmt.Fprintf(body, "Hi %s,\n\n", user.Realname)
+	fmt.Fprintf(body, "A password reset was requested for your account %s of %s. ", user.Username, a.config.Name)
+	fmt.Fprintf(body, "It is valid for %d minutes and can be used once, ignore this message if you did not request it.\n\n", int(expired.Minutes()))
+
+	if url := a.config.Auth.ResetURL; url != "" {
+		fmt.Fprintf(body, "%s\n", strings.ReplaceAll(url, "{token}", token))
+	} else {
+		fmt.Fprintf(body, "Reset token: %s\n", token)
+	}
+
+	return &lib.NotifyMessage{
+		To:      user.Email,
+		Subject: fmt.Sprintf("[%s] Reset your password", a.config.Name),
+		Body:    body.String(),
+	}
+}
+
+// Reset redeems the token and replaces the password of its user,
+// all the sessions of the user are revoked
+func (a PasswordResetService) Reset(token, password string) error {
+	var (
+		key    = wrapperResetTokenKey(hash.SHA256(token))
+		userID string
+	)
+
+	if err := a.redis.GetSkippingLocalCache(key, &userID); err != nil {
+		if errors.Is(err, errors.RedisKeyNoExist) {
+			return errors.UserResetTokenInvalid
+		}
+
+		return err
+	}
+
+	user, err := a.userService.Get(userID)
+	if err != nil {
+		return err
+	} else if user.Status != 1 {
+		return errors.UserIsDisable
+	}
+
+	// a password refused by the policy does not spend the token
+	if err := a.userService.CheckPassword(user, password); err != nil {
+		return err
+	}
+
+	// the token is spent by whoever deletes it first
+	if ok, err := a.redis.Delete(key); err != nil {
+		return err
+	} else if !ok {
+		return errors.UserResetTokenInvalid
+	}
+
+	if _, err := a.redis.Delete(wrapperUserResetKey(userID)); err != nil {
+		return err
+	}
+
+	if err := a.userService.ResetPassword(userID, password); err != nil {
+		return err
+	}
+
+	return a.authService.DestroyUserSessions(userID)
+}
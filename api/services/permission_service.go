@@ -0,0 +1,314 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RealLiuSha/echo-admin/api/repository"
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/pkg/slice"
+
+	"gorm.io/gorm"
+)
+
+// the permissions of a user are cached by the version of the policy, a change of the policy misses the cache
+const userPermissionsExpired = time.Hour
+
+// PermissionChecksLimit caps the checks of one bulk request
+const PermissionChecksLimit = 100
+
+func wrapperUserPermissionsKey(userID string, version int64) string {
+	return fmt.Sprintf("auth:permissions:%s:%d", userID, version)
+}
+
+// PermissionService explains the decisions of the casbin policy and lists the actions users are granted
+type PermissionService struct {
+	logger                       lib.Logger
+	redis                        lib.Redis
+	casbinService                CasbinService
+	userService                  UserService
+	userRoleRepository           repository.UserRoleRepository
+	roleRepository               repository.RoleRepository
+	roleMenuRepository           repository.RoleMenuRepository
+	menuRepository               repository.MenuRepository
+	menuActionRepository         repository.MenuActionRepository
+	menuActionResourceRepository repository.MenuActionResourceRepository
+}
+
+// NewPermissionService creates a new permission service
+func NewPermissionService(
+	logger lib.Logger,
+	redis lib.Redis,
+	casbinService CasbinService,
+	userService UserService,
+	userRoleRepository repository.UserRoleRepository,
+	roleRepository repository.RoleRepository,
+	roleMenuRepository repository.RoleMenuRepository,
+	menuRepository repository.MenuRepository,
+	menuActionRepository repository.MenuActionRepository,
+	menuActionResourceRepository repository.MenuActionResourceRepository,
+) PermissionService {
+	return PermissionService{
+		logger:                       logger,
+		redis:                        redis,
+		casbinService:                casbinService,
+		userService:                  userService,
+		userRoleRepository:           userRoleRepository,
+		roleRepository:               roleRepository,
+		roleMenuRepository:           roleMenuRepository,
+		menuRepository:               menuRepository,
+		menuActionRepository:         menuActionRepository,
+		menuActionResourceRepository: menuActionResourceRepository,
+	}
+}
+
+// WithTrx delegates transaction to repository database
+func (a PermissionService) WithTrx(trxHandle *gorm.DB) PermissionService {
+	a.casbinService = a.casbinService.WithTrx(trxHandle)
+	a.userService = a.userService.WithTrx(trxHandle)
+	a.userRoleRepository = a.userRoleRepository.WithTrx(trxHandle)
+	a.roleRepository = a.roleRepository.WithTrx(trxHandle)
+	a.roleMenuRepository = a.roleMenuRepository.WithTrx(trxHandle)
+	a.menuRepository = a.menuRepository.WithTrx(trxHandle)
+	a.menuActionRepository = a.menuActionRepository.WithTrx(trxHandle)
+	a.menuActionResourceRepository = a.menuActionResourceRepository.WithTrx(trxHandle)
+
+	return a
+}
+
+// Check tells whether the user may request the path with the method,
+// and which policy, role and menu actions allow it
+func (a PermissionService) Check(param *models.PermissionCheckParam) (*models.PermissionCheckResult, error) {
+	userID, err := a.userID(param)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PermissionCheckResult{
+		UserID: userID,
+		Method: strings.ToUpper(param.Method),
+		// the policy only knows the paths, not their queries
+		Path: strings.SplitN(param.Path, "?", 2)[0],
+	}
+
+	// every policy matches the super admin, the explanation would name a random one
+	if a.userService.IsSuperAdmin(userID) {
+		result.Allowed, result.SuperAdmin = true, true
+		return result, nil
+	}
+
+	if result.Roles, result.InheritedRoles, err = a.casbinService.GetRolesForUser(userID); err != nil {
+		return nil, err
+	}
+
+	allowed, explain, err := a.casbinService.Enforcer.EnforceEx(userID, result.Path, result.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Allowed = allowed
+	if !allowed || len(explain) != 3 {
+		return result, nil
+	}
+
+	result.Policy = explain
+	result.RoleID = explain[0]
+	result.RoleInherited = !slice.ContainsString(result.Roles, result.RoleID)
+
+	role, err := a.roleRepository.Get(result.RoleID)
+	if err != nil && !errors.Is(err, errors.DatabaseRecordNotFound) {
+		return nil, err
+	} else if role != nil {
+		result.RoleName = role.Name
+	}
+
+	if result.Grants, err = a.grants(result.RoleID, explain[1], explain[2]); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// CheckAll checks the requests one by one, at most PermissionChecksLimit of them
+func (a PermissionService) CheckAll(params models.PermissionCheckParams) (models.PermissionCheckResults, error) {
+	if len(params) > PermissionChecksLimit {
+		return nil, errors.PermissionTooManyChecks
+	}
+
+	results := make(models.PermissionCheckResults, 0, len(params))
+	for _, param := range params {
+		result, err := a.Check(param)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// userID resolves the user of the check within the data scope of the current user,
+// the super admin lives in the config and is out of any data scope
+func (a PermissionService) userID(param *models.PermissionCheckParam) (string, error) {
+	if admin := a.userService.GetSuperAdmin(); admin != nil &&
+		(param.UserID == admin.ID || (param.UserID == "" && param.Username == admin.Username)) {
+		return admin.ID, nil
+	}
+
+	if param.UserID == "" && param.Username != "" {
+		user, err := a.userService.GetByUsername(param.Username)
+		if err != nil {
+			return "", err
+		}
+
+		return user.ID, nil
+	}
+
+	if _, err := a.userService.Get(param.UserID); err != nil {
+		if errors.Is(err, errors.DatabaseRecordNotFound) {
+			return "", errors.UserRecordNotFound
+		}
+
+		return "", err
+	}
+
+	return param.UserID, nil
+}
+
+// grants returns the menu actions of the role that hold the resource of the policy
+func (a PermissionService) grants(roleID, path, method string) (models.PermissionGrants, error) {
+	roleMenuQR, err := a.roleMenuRepository.Query(&models.RoleMenuQueryParam{RoleID: roleID})
+	if err != nil {
+		return nil, err
+	} else if len(roleMenuQR.List) == 0 {
+		return nil, nil
+	}
+
+	menuResourceQR, err := a.menuActionResourceRepository.Query(&models.MenuActionResourceQueryParam{
+		MenuIDs: roleMenuQR.List.ToMenuIDs(),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var actionIDs, menuIDs []string
+	mResources := menuResourceQR.List.ToActionIDMap()
+	for _, roleMenu := range roleMenuQR.List {
+		for _, resource := range mResources[roleMenu.ActionID] {
+			if resource.Path == path && resource.Method == method {
+				actionIDs = append(actionIDs, roleMenu.ActionID)
+				menuIDs = append(menuIDs, roleMenu.MenuID)
+				break
+			}
+		}
+	}
+
+	if len(actionIDs) == 0 {
+		return nil, nil
+	}
+
+	menuActionQR, err := a.menuActionRepository.Query(&models.MenuActionQueryParam{IDs: actionIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	menuQR, err := a.menuRepository.Query(&models.MenuQueryParam{IDs: menuIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	mMenus := menuQR.List.ToMap()
+	grants := make(models.PermissionGrants, 0, len(menuActionQR.List))
+	for _, action := range menuActionQR.List {
+		grant := &models.PermissionGrant{
+			MenuID:     action.MenuID,
+			ActionID:   action.ID,
+			ActionCode: action.Code,
+			ActionName: action.Name,
+		}
+
+		if menu, ok := mMenus[action.MenuID]; ok {
+			grant.MenuName = menu.Name
+		}
+
+		grants = append(grants, grant)
+	}
+
+	return grants, nil
+}
+
+// GetUserPermissions returns the codes of the actions the user is granted by its roles, by menu id
+func (a PermissionService) GetUserPermissions(userID string) (models.UserPermissions, error) {
+	version, err := a.casbinService.PolicyVersion()
+	if err != nil {
+		a.logger.Zap.Errorf("Error to get the casbin policy version: %v", err)
+		return a.loadUserPermissions(userID)
+	}
+
+	key := wrapperUserPermissionsKey(userID, version)
+	permissions := make(models.UserPermissions)
+	if err := a.redis.Get(key, &permissions); err == nil {
+		return permissions, nil
+	} else if !errors.Is(err, errors.RedisKeyNoExist) {
+		a.logger.Zap.Errorf("Error to get the cached permissions of user %s: %v", userID, err)
+	}
+
+	if permissions, err = a.loadUserPermissions(userID); err != nil {
+		return nil, err
+	}
+
+	if err := a.redis.Set(key, permissions, userPermissionsExpired); err != nil {
+		a.logger.Zap.Errorf("Error to cache the permissions of user %s: %v", userID, err)
+	}
+
+	return permissions, nil
+}
+
+// loadUserPermissions collects the actions of the role menus of the user and its inherited roles,
+// the super admin is granted every action
+func (a PermissionService) loadUserPermissions(userID string) (models.UserPermissions, error) {
+	permissions := make(models.UserPermissions)
+
+	var actionIDs []string
+	if !a.userService.IsSuperAdmin(userID) {
+		userRoleQR, err := a.userRoleRepository.Query(&models.UserRoleQueryParam{UserID: userID})
+		if err != nil {
+			return nil, err
+		}
+
+		roleIDs, err := a.userService.EffectiveRoleIDs(userRoleQR.List.ToRoleIDs())
+		if err != nil {
+			return nil, err
+		} else if len(roleIDs) == 0 {
+			return permissions, nil
+		}
+
+		roleMenuQR, err := a.roleMenuRepository.Query(&models.RoleMenuQueryParam{RoleIDs: roleIDs})
+		if err != nil {
+			return nil, err
+		} else if actionIDs = roleMenuQR.List.ToActionIDs(); len(actionIDs) == 0 {
+			return permissions, nil
+		}
+	}
+
+	menuActionQR, err := a.menuActionRepository.Query(&models.MenuActionQueryParam{IDs: actionIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, action := range menuActionQR.List {
+		permissions[action.MenuID] = append(permissions[action.MenuID], action.Code)
+	}
+
+	for _, codes := range permissions {
+		sort.Strings(codes)
+	}
+
+	return permissions, nil
+}
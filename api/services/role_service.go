@@ -4,6 +4,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/RealLiuSha/echo-admin/api/repository"
+	"github.com/RealLiuSha/echo-admin/constants"
 	"github.com/RealLiuSha/echo-admin/errors"
 	"github.com/RealLiuSha/echo-admin/lib"
 	"github.com/RealLiuSha/echo-admin/models"
@@ -19,6 +20,11 @@ type RoleService struct {
 	roleMenuRepository   repository.RoleMenuRepository
 	menuRepository       repository.MenuRepository
 	menuActionRepository repository.MenuActionRepository
+
+	roleInheritRepository repository.RoleInheritRepository
+
+	roleDepartmentRepository repository.RoleDepartmentRepository
+	departmentRepository     repository.DepartmentRepository
 }
 
 // NewRoleService creates a new roleservice
@@ -30,6 +36,9 @@ func NewRoleService(
 	roleMenuRepository repository.RoleMenuRepository,
 	menuRepository repository.MenuRepository,
 	menuActionRepository repository.MenuActionRepository,
+	roleInheritRepository repository.RoleInheritRepository,
+	roleDepartmentRepository repository.RoleDepartmentRepository,
+	departmentRepository repository.DepartmentRepository,
 ) RoleService {
 	return RoleService{
 		logger:               logger,
@@ -39,6 +48,11 @@ func NewRoleService(
 		roleMenuRepository:   roleMenuRepository,
 		menuRepository:       menuRepository,
 		menuActionRepository: menuActionRepository,
+
+		roleInheritRepository: roleInheritRepository,
+
+		roleDepartmentRepository: roleDepartmentRepository,
+		departmentRepository:     departmentRepository,
 	}
 }
 
@@ -47,6 +61,10 @@ func (a RoleService) WithTrx(trxHandle *gorm.DB) RoleService {
 	a.roleRepository = a.roleRepository.WithTrx(trxHandle)
 	a.userRepository = a.userRepository.WithTrx(trxHandle)
 	a.roleMenuRepository = a.roleMenuRepository.WithTrx(trxHandle)
+	a.roleInheritRepository = a.roleInheritRepository.WithTrx(trxHandle)
+	a.roleDepartmentRepository = a.roleDepartmentRepository.WithTrx(trxHandle)
+	a.departmentRepository = a.departmentRepository.WithTrx(trxHandle)
+	a.casbinService = a.casbinService.WithTrx(trxHandle)
 
 	return a
 }
@@ -67,6 +85,30 @@ func (a RoleService) QueryRoleMenus(roleID string) (models.RoleMenus, error) {
 	return roleMenuQR.List, nil
 }
 
+func (a RoleService) QueryRoleInherits(roleID string) (models.RoleInherits, error) {
+	roleInheritQR, err := a.roleInheritRepository.Query(&models.RoleInheritQueryParam{
+		RoleID: roleID,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return roleInheritQR.List, nil
+}
+
+func (a RoleService) QueryRoleDepartments(roleID string) (models.RoleDepartments, error) {
+	roleDepartmentQR, err := a.roleDepartmentRepository.Query(&models.RoleDepartmentQueryParam{
+		RoleID: roleID,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return roleDepartmentQR.List, nil
+}
+
 func (a RoleService) Get(id string) (*models.Role, error) {
 	role, err := a.roleRepository.Get(id)
 	if err != nil {
@@ -78,7 +120,19 @@ func (a RoleService) Get(id string) (*models.Role, error) {
 		return nil, err
 	}
 
+	roleInherits, err := a.QueryRoleInherits(id)
+	if err != nil {
+		return nil, err
+	}
+
+	roleDepartments, err := a.QueryRoleDepartments(id)
+	if err != nil {
+		return nil, err
+	}
+
 	role.RoleMenus = roleMenus
+	role.RoleInherits = roleInherits
+	role.RoleDepartments = roleDepartments
 	return role, nil
 }
 
@@ -106,6 +160,146 @@ func (a RoleService) CheckRoleMenu(rMenu *models.RoleMenu) error {
 	return nil
 }
 
+// CheckDataScope refuses unknown data scopes and departments, only the custom scope keeps its departments
+func (a RoleService) CheckDataScope(role *models.Role) error {
+	switch role.DataScope {
+	case constants.DataScopeAll, constants.DataScopeDepartment, constants.DataScopeDepartmentAndChildren,
+		constants.DataScopeSelf:
+		role.RoleDepartments = nil
+		return nil
+	case constants.DataScopeCustom:
+	default:
+		return errors.RoleInvalidDataScope
+	}
+
+	for _, roleDepartment := range role.RoleDepartments {
+		if _, err := a.departmentRepository.Get(roleDepartment.DepartmentID); err != nil {
+			return errors.Wrap(err, "department id")
+		}
+	}
+
+	return nil
+}
+
+// CheckRoleInherits refuses unknown roles, cycles and chains longer than casbin follows
+func (a RoleService) CheckRoleInherits(roleID string, roleInherits models.RoleInherits) error {
+	roleInheritQR, err := a.roleInheritRepository.Query(&models.RoleInheritQueryParam{})
+	if err != nil {
+		return err
+	}
+
+	graph := make(map[string][]string)
+	for _, item := range roleInheritQR.List {
+		if item.RoleID != roleID {
+			graph[item.RoleID] = append(graph[item.RoleID], item.InheritID)
+		}
+	}
+
+	for _, item := range roleInherits {
+		if item.InheritID == roleID {
+			return errors.RoleInheritCycle
+		}
+
+		if _, err := a.roleRepository.Get(item.InheritID); err != nil {
+			return errors.Wrap(err, "inherit id")
+		}
+
+		graph[roleID] = append(graph[roleID], item.InheritID)
+	}
+
+	return checkRoleInheritGraph(graph)
+}
+
+// casbin creates the role manager of g2 with a hierarchy level of 10, which follows a role
+// through up to 11 links of g2, so an inheritance chain of 10 links is always resolved
+const maxRoleInheritDepth = 10
+
+// checkRoleInheritGraph walks the inheritance of every role,
+// a role met again on its own path is a cycle
+func checkRoleInheritGraph(graph map[string][]string) error {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	state := make(map[string]int)
+	depth := make(map[string]int)
+
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visiting:
+			return errors.RoleInheritCycle
+		case visited:
+			return nil
+		}
+
+		state[id] = visiting
+		for _, next := range graph[id] {
+			if err := visit(next); err != nil {
+				return err
+			}
+
+			if d := depth[next] + 1; d > depth[id] {
+				depth[id] = d
+			}
+		}
+
+		state[id] = visited
+		if depth[id] > maxRoleInheritDepth {
+			return errors.RoleInheritTooDeep
+		}
+
+		return nil
+	}
+
+	for id := range graph {
+		if err := visit(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a RoleService) CompareRoleInherits(oRoleInherits, nRoleInherits models.RoleInherits) (aList, dList models.RoleInherits) {
+	oMap := oRoleInherits.ToMap()
+	nMap := nRoleInherits.ToMap()
+
+	for k, nRoleInherit := range nMap {
+		if _, ok := oMap[k]; ok {
+			delete(oMap, k)
+			continue
+		}
+		aList = append(aList, nRoleInherit)
+	}
+
+	for _, oRoleInherit := range oMap {
+		dList = append(dList, oRoleInherit)
+	}
+	return
+}
+
+func (a RoleService) CompareRoleDepartments(oRoleDepartments, nRoleDepartments models.RoleDepartments) (aList, dList models.RoleDepartments) {
+	oMap := oRoleDepartments.ToMap()
+	nMap := nRoleDepartments.ToMap()
+
+	for k, item := range nMap {
+		if _, ok := oMap[k]; ok {
+			delete(oMap, k)
+			continue
+		}
+
+		aList = append(aList, item)
+	}
+
+	for _, item := range oMap {
+		dList = append(dList, item)
+	}
+
+	return
+}
+
 func (a RoleService) CompareRoleMenus(oRoleMenus, nRoleMenus models.RoleMenus) (aList, dList models.RoleMenus) {
 	oMap := oRoleMenus.ToMap()
 	nMap := nRoleMenus.ToMap()
@@ -130,6 +324,36 @@ func (a RoleService) Create(role *models.Role) (id string, err error) {
 	}
 
 	role.ID = uuid.MustString()
+	if err = a.CheckRoleInherits(role.ID, role.RoleInherits); err != nil {
+		return
+	}
+
+	if role.DataScope == "" {
+		role.DataScope = constants.DataScopeAll
+	}
+
+	if err = a.CheckDataScope(role); err != nil {
+		return
+	}
+
+	for _, roleDepartment := range role.RoleDepartments.ToMap() {
+		roleDepartment.ID = uuid.MustString()
+		roleDepartment.RoleID = role.ID
+
+		if err = a.roleDepartmentRepository.Create(roleDepartment); err != nil {
+			return
+		}
+	}
+
+	for _, roleInherit := range role.RoleInherits.ToMap() {
+		roleInherit.ID = uuid.MustString()
+		roleInherit.RoleID = role.ID
+
+		if err = a.roleInheritRepository.Create(roleInherit); err != nil {
+			return
+		}
+	}
+
 	for _, roleMenu := range role.RoleMenus {
 		roleMenu.ID = uuid.MustString()
 		roleMenu.RoleID = role.ID
@@ -147,7 +371,7 @@ func (a RoleService) Create(role *models.Role) (id string, err error) {
 		return
 	}
 
-	a.casbinService.Enforcer.LoadPolicy()
+	a.casbinService.UpdateRoles(role.ID)
 	return role.ID, nil
 }
 
@@ -185,11 +409,55 @@ func (a RoleService) Update(id string, role *models.Role) error {
 		}
 	}
 
+	if err := a.CheckRoleInherits(id, role.RoleInherits); err != nil {
+		return err
+	}
+
+	aRoleInherits, dRoleInherits := a.CompareRoleInherits(oRole.RoleInherits, role.RoleInherits)
+	for _, aRoleInherit := range aRoleInherits {
+		aRoleInherit.ID = uuid.MustString()
+		aRoleInherit.RoleID = id
+
+		if err := a.roleInheritRepository.Create(aRoleInherit); err != nil {
+			return err
+		}
+	}
+
+	for _, dRoleInherit := range dRoleInherits {
+		if err := a.roleInheritRepository.Delete(dRoleInherit.ID); err != nil {
+			return err
+		}
+	}
+
+	if role.DataScope == "" {
+		role.DataScope = oRole.DataScope
+	}
+
+	if err := a.CheckDataScope(role); err != nil {
+		return err
+	}
+
+	aRoleDepartments, dRoleDepartments := a.CompareRoleDepartments(oRole.RoleDepartments, role.RoleDepartments)
+	for _, aRoleDepartment := range aRoleDepartments {
+		aRoleDepartment.ID = uuid.MustString()
+		aRoleDepartment.RoleID = id
+
+		if err := a.roleDepartmentRepository.Create(aRoleDepartment); err != nil {
+			return err
+		}
+	}
+
+	for _, dRoleDepartment := range dRoleDepartments {
+		if err := a.roleDepartmentRepository.Delete(dRoleDepartment.ID); err != nil {
+			return err
+		}
+	}
+
 	if err := a.roleRepository.Update(id, role); err != nil {
 		return err
 	}
 
-	a.casbinService.Enforcer.LoadPolicy()
+	a.casbinService.UpdateRoles(id)
 	return nil
 }
 
@@ -199,7 +467,7 @@ func (a RoleService) Delete(id string) error {
 		return err
 	}
 
-	userQR, err := a.userRepository.Query(&models.UserQueryParam{
+	userQR, err := a.userRepository.WithoutDataScope().Query(&models.UserQueryParam{
 		RoleIDs: []string{id},
 	})
 
@@ -209,15 +477,33 @@ func (a RoleService) Delete(id string) error {
 		return errors.RoleNotAllowDeleteWithUser
 	}
 
+	roleInheritQR, err := a.roleInheritRepository.Query(&models.RoleInheritQueryParam{
+		InheritID: id,
+	})
+
+	if err != nil {
+		return err
+	} else if roleInheritQR.Pagination.Total > 0 {
+		return errors.RoleNotAllowDeleteInherited
+	}
+
 	if err := a.roleMenuRepository.DeleteByRoleID(id); err != nil {
 		return err
 	}
 
+	if err := a.roleInheritRepository.DeleteByRoleID(id); err != nil {
+		return err
+	}
+
+	if err := a.roleDepartmentRepository.DeleteByRoleID(id); err != nil {
+		return err
+	}
+
 	if err := a.roleRepository.Delete(id); err != nil {
 		return err
 	}
 
-	a.casbinService.Enforcer.LoadPolicy()
+	a.casbinService.UpdateRoles(id)
 	return nil
 }
 
@@ -231,6 +517,6 @@ func (a RoleService) UpdateStatus(id string, status int) error {
 		return err
 	}
 
-	a.casbinService.Enforcer.LoadPolicy()
+	a.casbinService.UpdateRoles(id)
 	return nil
 }
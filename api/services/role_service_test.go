@@ -0,0 +1,41 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+)
+
+// chain links r0 to r1 and so on, n links in all
+func chain(n int) map[string][]string {
+	graph := make(map[string][]string)
+	for i := 0; i < n; i++ {
+		graph[fmt.Sprintf("r%d", i)] = []string{fmt.Sprintf("r%d", i+1)}
+	}
+
+	return graph
+}
+
+func TestCheckRoleInheritGraph(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph map[string][]string
+		err   error
+	}{
+		{"empty", map[string][]string{}, nil},
+		{"self loop", map[string][]string{"a": {"a"}}, errors.RoleInheritCycle},
+		{"cycle of three", map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}}, errors.RoleInheritCycle},
+		{"diamond", map[string][]string{"a": {"b", "c"}, "b": {"d"}, "c": {"d"}}, nil},
+		{"longest chain", chain(maxRoleInheritDepth), nil},
+		{"too deep chain", chain(maxRoleInheritDepth + 1), errors.RoleInheritTooDeep},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.err, checkRoleInheritGraph(test.graph))
+		})
+	}
+}
@@ -9,4 +9,13 @@ var Module = fx.Options(
 	fx.Provide(NewMenuService),
 	fx.Provide(NewCasbinService),
 	fx.Provide(NewAuthService),
+	fx.Provide(NewTwoFactorService),
+	fx.Provide(NewLoginGuardService),
+	fx.Provide(NewPasswordResetService),
+	fx.Provide(NewAccessTokenService),
+	fx.Provide(NewOIDCService),
+	fx.Provide(NewLoginLogService),
+	fx.Provide(NewDepartmentService),
+	fx.Provide(NewDataScopeService),
+	fx.Provide(NewPermissionService),
 )
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/RealLiuSha/echo-admin/api/repository"
+	"github.com/RealLiuSha/echo-admin/errors"
+	"github.com/RealLiuSha/echo-admin/lib"
+	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+	"github.com/RealLiuSha/echo-admin/pkg/hash"
+	"github.com/RealLiuSha/echo-admin/pkg/random"
+	"github.com/RealLiuSha/echo-admin/pkg/slice"
+	"github.com/RealLiuSha/echo-admin/pkg/totp"
+	"github.com/RealLiuSha/echo-admin/pkg/uuid"
+)
+
+const (
+	// lifetime of the second step of a login in seconds
+	twoFactorChallengeExpired = 300
+	// wrong codes accepted before the challenge is dropped
+	twoFactorChallengeAttempts = 5
+	recoveryCodeCount          = 10
+)
+
+// TwoFactorService service layer
+type TwoFactorService struct {
+	logger                     lib.Logger
+	config                     lib.Config
+	redis                      lib.Redis
+	userRepository             repository.UserRepository
+	userRoleRepository         repository.UserRoleRepository
+	roleRepository             repository.RoleRepository
+	userRecoveryCodeRepository repository.UserRecoveryCodeRepository
+}
+
+// NewTwoFactorService creates a new two-factor service
+func NewTwoFactorService(
+	logger lib.Logger,
+	config lib.Config,
+	redis lib.Redis,
+	userRepository repository.UserRepository,
+	userRoleRepository repository.UserRoleRepository,
+	roleRepository repository.RoleRepository,
+	userRecoveryCodeRepository repository.UserRecoveryCodeRepository,
+) TwoFactorService {
+	return TwoFactorService{
+		logger:                     logger,
+		config:                     config,
+		redis:                      redis,
+		userRepository:             userRepository,
+		userRoleRepository:         userRoleRepository,
+		roleRepository:             roleRepository,
+		userRecoveryCodeRepository: userRecoveryCodeRepository,
+	}
+}
+
+// WithTrx delegates transaction to repository database
+func (a TwoFactorService) WithTrx(trxHandle *gorm.DB) TwoFactorService {
+	a.userRepository = a.userRepository.WithTrx(trxHandle)
+	a.userRecoveryCodeRepository = a.userRecoveryCodeRepository.WithTrx(trxHandle)
+
+	return a
+}
+
+func wrapperTwoFactorChallengeKey(id string) string {
+	return fmt.Sprintf("auth:2fa:challenge:%s", id)
+}
+
+func wrapperTwoFactorAttemptsKey(id string) string {
+	return fmt.Sprintf("auth:2fa:attempts:%s", id)
+}
+
+func wrapperTwoFactorCounterKey(userID string) string {
+	return fmt.Sprintf("auth:2fa:counter:%s", userID)
+}
+
+func (a TwoFactorService) issuer() string {
+	if conf := a.config.Auth.TwoFactor; conf != nil && conf.Issuer != "" {
+		return conf.Issuer
+	}
+
+	return a.config.Name
+}
+
+// Required tells whether one of the roles of the user demands two-factor authentication
+func (a TwoFactorService) Required(userID string) (bool, error) {
+	conf := a.config.Auth.TwoFactor
+	if conf == nil || len(conf.RequiredRoles) == 0 {
+		return false, nil
+	}
+
+	userRoleQR, err := a.userRoleRepository.Query(&models.UserRoleQueryParam{UserID: userID})
+	if err != nil {
+		return false, err
+	} else if len(userRoleQR.List) == 0 {
+		return false, nil
+	}
+
+	roleQR, err := a.roleRepository.Query(&models.RoleQueryParam{
+		IDs:    userRoleQR.List.ToRoleIDs(),
+		Status: 1,
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range roleQR.List {
+		if slice.ContainsString(conf.RequiredRoles, role.Name) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Challenge starts the second step of the login of the user
+func (a TwoFactorService) Challenge(user *models.User) (*dto.LoginChallenge, error) {
+	id := random.Token(32)
+	challenge := &dto.TwoFactorChallenge{UserID: user.ID}
+
+	expired := twoFactorChallengeExpired * time.Second
+	if err := a.redis.Set(wrapperTwoFactorChallengeKey(id), challenge, expired); err != nil {
+		return nil, err
+	}
+
+	return &dto.LoginChallenge{
+		Type:      "totp",
+		Challenge: id,
+		ExpiresIn: twoFactorChallengeExpired,
+	}, nil
+}
+
+// VerifyChallenge completes the second step of a login with a totp or recovery code,
+// and returns the user of the login, the user is also returned with errors.TwoFactorCodeInvalid
+// so that the failed attempt can be recorded
+func (a TwoFactorService) VerifyChallenge(id, code string) (*models.User, error) {
+	key := wrapperTwoFactorChallengeKey(id)
+	challenge := new(dto.TwoFactorChallenge)
+
+	if err := a.redis.GetSkippingLocalCache(key, challenge); err != nil {
+		if errors.Is(err, errors.RedisKeyNoExist) {
+			return nil, errors.TwoFactorChallengeInvalid
+		}
+
+		return nil, err
+	}
+
+	ttl, err := a.redis.TTL(key)
+	if err != nil {
+		return nil, err
+	} else if ttl < time.Second {
+		return nil, errors.TwoFactorChallengeInvalid
+	}
+
+	// every code counts as an attempt, so that concurrent guesses can not pass the limit
+	attemptsKey := wrapperTwoFactorAttemptsKey(id)
+	attempts, err := a.redis.Incr(attemptsKey, ttl)
+	if err != nil {
+		return nil, err
+	} else if attempts > twoFactorChallengeAttempts {
+		if _, err := a.redis.Delete(key, attemptsKey); err != nil {
+			return nil, err
+		}
+
+		return nil, errors.TwoFactorChallengeInvalid
+	}
+
+	user, err := a.userRepository.Get(challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.verify(user, code); err != nil {
+		if !errors.Is(err, errors.TwoFactorCodeInvalid) {
+			return nil, err
+		}
+
+		// the challenge is dropped after too many wrong codes
+		if attempts >= twoFactorChallengeAttempts {
+			if _, err := a.redis.Delete(key, attemptsKey); err != nil {
+				return nil, err
+			}
+		}
+
+		return user, errors.TwoFactorCodeInvalid
+	}
+
+	// the challenge is redeemed by whoever deletes it first
+	if ok, err := a.redis.Delete(key); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errors.TwoFactorChallengeInvalid
+	}
+
+	if _, err := a.redis.Delete(attemptsKey); err != nil {
+		a.logger.Zap.Errorf("Error to delete the attempts of the challenge: %v", err)
+	}
+
+	return user, nil
+}
+
+// verify accepts a totp code or one of the recovery codes of the user
+func (a TwoFactorService) verify(user *models.User, code string) error {
+	if !user.TwoFactorEnabled {
+		return errors.TwoFactorNotEnabled
+	}
+
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) == totp.Digits {
+		return a.verifyCode(user.ID, user.TwoFactorSecret, code)
+	}
+
+	return a.useRecoveryCode(user.ID, code)
+}
+
+// verifyCode accepts a totp code only once
+func (a TwoFactorService) verifyCode(userID, secret, code string) error {
+	counter, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return errors.TwoFactorCodeInvalid
+	}
+
+	var (
+		key  = wrapperTwoFactorCounterKey(userID)
+		last int64
+	)
+
+	err := a.redis.GetSkippingLocalCache(key, &last)
+	if err != nil && !errors.Is(err, errors.RedisKeyNoExist) {
+		return err
+	} else if counter <= last {
+		return errors.TwoFactorCodeInvalid
+	}
+
+	// the counter only needs to outlive the validity window of the code
+	expired := time.Duration((2*totp.Skew+1)*totp.Period) * time.Second
+	return a.redis.Set(key, counter, expired)
+}
+
+func (a TwoFactorService) useRecoveryCode(userID, code string) error {
+	codeQR, err := a.userRecoveryCodeRepository.Query(&models.UserRecoveryCodeQueryParam{
+		UserID: userID,
+		Code:   hash.SHA256(code),
+	})
+
+	if err != nil {
+		return err
+	} else if len(codeQR.List) == 0 {
+		return errors.TwoFactorCodeInvalid
+	}
+
+	// the code is spent by whoever deletes it first
+	if n, err := a.userRecoveryCodeRepository.Delete(codeQR.List[0].ID); err != nil {
+		return err
+	} else if n == 0 {
+		return errors.TwoFactorCodeInvalid
+	}
+
+	return nil
+}
+
+// Enroll generates a new secret for the user,
+// it is enabled once a code of the secret is confirmed by Activate
+func (a TwoFactorService) Enroll(userID string) (*dto.TwoFactorEnrollment, error) {
+	user, err := a.userRepository.Get(userID)
+	if err != nil {
+		return nil, err
+	} else if user.TwoFactorEnabled {
+		return nil, errors.TwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.userRepository.UpdateTwoFactor(userID, secret, false); err != nil {
+		return nil, err
+	}
+
+	return &dto.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totp.URI(secret, a.issuer(), user.Username),
+	}, nil
+}
+
+// Activate enables the enrolled secret and returns the first recovery codes
+func (a TwoFactorService) Activate(userID, code string) (*dto.RecoveryCodes, error) {
+	user, err := a.userRepository.Get(userID)
+	if err != nil {
+		return nil, err
+	} else if user.TwoFactorEnabled {
+		return nil, errors.TwoFactorAlreadyEnabled
+	} else if user.TwoFactorSecret == "" {
+		return nil, errors.TwoFactorNotEnrolled
+	}
+
+	if err := a.verifyCode(userID, user.TwoFactorSecret, code); err != nil {
+		return nil, err
+	}
+
+	if err := a.userRepository.UpdateTwoFactor(userID, user.TwoFactorSecret, true); err != nil {
+		return nil, err
+	}
+
+	return a.generateRecoveryCodes(userID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user
+func (a TwoFactorService) RegenerateRecoveryCodes(userID, code string) (*dto.RecoveryCodes, error) {
+	user, err := a.userRepository.Get(userID)
+	if err != nil {
+		return nil, err
+	} else if !user.TwoFactorEnabled {
+		return nil, errors.TwoFactorNotEnabled
+	}
+
+	if err := a.verifyCode(userID, user.TwoFactorSecret, code); err != nil {
+		return nil, err
+	}
+
+	return a.generateRecoveryCodes(userID)
+}
+
+// Disable turns off the two-factor authentication of the user, unless the user roles require it
+func (a TwoFactorService) Disable(userID, code string) error {
+	user, err := a.userRepository.Get(userID)
+	if err != nil {
+		return err
+	}
+
+	if required, err := a.Required(userID); err != nil {
+		return err
+	} else if required {
+		return errors.TwoFactorRequired
+	}
+
+	if err := a.verify(user, code); err != nil {
+		return err
+	}
+
+	return a.Reset(userID)
+}
+
+// Reset removes the secret and recovery codes of the user, so that the user can enroll again
+func (a TwoFactorService) Reset(userID string) error {
+	if _, err := a.userRepository.Get(userID); err != nil {
+		return err
+	}
+
+	if err := a.userRepository.UpdateTwoFactor(userID, "", false); err != nil {
+		return err
+	}
+
+	if err := a.userRecoveryCodeRepository.DeleteByUserID(userID); err != nil {
+		return err
+	}
+
+	_, err := a.redis.Delete(wrapperTwoFactorCounterKey(userID))
+	return err
+}
+
+func (a TwoFactorService) generateRecoveryCodes(userID string) (*dto.RecoveryCodes, error) {
+	if err := a.userRecoveryCodeRepository.DeleteByUserID(userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code := random.Token(5)
+		codes[i] = code[:5] + "-" + code[5:]
+
+		err := a.userRecoveryCodeRepository.Create(&models.UserRecoveryCode{
+			ID:     uuid.MustString(),
+			UserID: userID,
+			Code:   hash.SHA256(code),
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &dto.RecoveryCodes{RecoveryCodes: codes}, nil
+}
@@ -1,30 +1,49 @@
 package services
 
 import (
+	"io/ioutil"
 	"sort"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 
 	"github.com/RealLiuSha/echo-admin/api/repository"
+	"github.com/RealLiuSha/echo-admin/constants"
 	"github.com/RealLiuSha/echo-admin/errors"
 	"github.com/RealLiuSha/echo-admin/lib"
 	"github.com/RealLiuSha/echo-admin/models"
+	"github.com/RealLiuSha/echo-admin/models/database"
 	"github.com/RealLiuSha/echo-admin/models/dto"
 	"github.com/RealLiuSha/echo-admin/pkg/hash"
+	"github.com/RealLiuSha/echo-admin/pkg/passwd"
 	"github.com/RealLiuSha/echo-admin/pkg/uuid"
 )
 
 // UserService service layer
 type UserService struct {
-	logger               lib.Logger
-	config               lib.Config
-	casbinService        CasbinService
-	userRepository       repository.UserRepository
-	userRoleRepository   repository.UserRoleRepository
-	menuRepository       repository.MenuRepository
-	menuActionRepository repository.MenuActionRepository
-	roleRepository       repository.RoleRepository
-	roleMenuRepository   repository.RoleMenuRepository
+	logger                     lib.Logger
+	config                     lib.Config
+	casbinService              CasbinService
+	authService                AuthService
+	departmentService          DepartmentService
+	accessTokenService         AccessTokenService
+	userRepository             repository.UserRepository
+	userRoleRepository         repository.UserRoleRepository
+	userDepartmentRepository   repository.UserDepartmentRepository
+	departmentRepository       repository.DepartmentRepository
+	departmentLeaderRepository repository.DepartmentLeaderRepository
+	menuRepository             repository.MenuRepository
+	menuActionRepository       repository.MenuActionRepository
+	roleRepository             repository.RoleRepository
+	roleMenuRepository         repository.RoleMenuRepository
+	roleInheritRepository      repository.RoleInheritRepository
+	roleDepartmentRepository   repository.RoleDepartmentRepository
+	historyRepository          repository.UserPasswordHistoryRepository
+	identityRepository         repository.UserIdentityRepository
+	passwords                  *hash.Passwords
+	policy                     *passwd.Policy
+	authenticator              Authenticator
 }
 
 // NewUserService creates a new userservice
@@ -34,43 +53,158 @@ func NewUserService(
 	userRoleRepository repository.UserRoleRepository,
 	roleRepository repository.RoleRepository,
 	roleMenuRepository repository.RoleMenuRepository,
+	roleInheritRepository repository.RoleInheritRepository,
+	roleDepartmentRepository repository.RoleDepartmentRepository,
 	menuRepository repository.MenuRepository,
 	menuActionRepository repository.MenuActionRepository,
+	historyRepository repository.UserPasswordHistoryRepository,
+	identityRepository repository.UserIdentityRepository,
+	userDepartmentRepository repository.UserDepartmentRepository,
+	departmentRepository repository.DepartmentRepository,
+	departmentLeaderRepository repository.DepartmentLeaderRepository,
 	casbinService CasbinService,
+	authService AuthService,
+	departmentService DepartmentService,
+	accessTokenService AccessTokenService,
 	config lib.Config,
 ) UserService {
 	return UserService{
-		logger:               logger,
-		config:               config,
-		userRepository:       userRepository,
-		userRoleRepository:   userRoleRepository,
-		roleRepository:       roleRepository,
-		roleMenuRepository:   roleMenuRepository,
-		menuRepository:       menuRepository,
-		menuActionRepository: menuActionRepository,
-		casbinService:        casbinService,
+		logger:                     logger,
+		config:                     config,
+		userRepository:             userRepository,
+		userRoleRepository:         userRoleRepository,
+		userDepartmentRepository:   userDepartmentRepository,
+		departmentRepository:       departmentRepository,
+		departmentLeaderRepository: departmentLeaderRepository,
+		roleRepository:             roleRepository,
+		roleMenuRepository:         roleMenuRepository,
+		roleInheritRepository:      roleInheritRepository,
+		roleDepartmentRepository:   roleDepartmentRepository,
+		menuRepository:             menuRepository,
+		menuActionRepository:       menuActionRepository,
+		historyRepository:          historyRepository,
+		identityRepository:         identityRepository,
+		casbinService:              casbinService,
+		authService:                authService,
+		departmentService:          departmentService,
+		accessTokenService:         accessTokenService,
+		passwords:                  NewPasswords(config, logger),
+		policy:                     newPasswordPolicy(config, logger),
+		authenticator:              newAuthenticator(config),
 	}
 }
 
+// NewPasswords hashes passwords with the configured algorithm,
+// the hashes of the other algorithms and the legacy sha256 hashes are still verified
+func NewPasswords(config lib.Config, logger lib.Logger) *hash.Passwords {
+	conf := config.Password
+	if conf == nil {
+		conf = &lib.PasswordConfig{}
+	}
+
+	argon2id := hash.NewArgon2id()
+	if conf.Argon2Time > 0 {
+		argon2id.Time = conf.Argon2Time
+	}
+	if conf.Argon2Memory > 0 {
+		argon2id.Memory = conf.Argon2Memory
+	}
+	if conf.Argon2Threads > 0 {
+		argon2id.Threads = conf.Argon2Threads
+	}
+
+	bcrypt := hash.NewBcrypt(conf.BcryptCost)
+
+	switch conf.Algorithm {
+	case "", "argon2id":
+		return hash.NewPasswords(argon2id, bcrypt, hash.LegacySHA256{})
+	case "bcrypt":
+		return hash.NewPasswords(bcrypt, argon2id, hash.LegacySHA256{})
+	default:
+		logger.Zap.Fatalf("Error to hash passwords: unsupported algorithm %s", conf.Algorithm)
+		return nil
+	}
+}
+
+// GetSuperAdmin returns the super admin of the config, nil when it is disabled
 func (a UserService) GetSuperAdmin() *models.User {
 	admin := a.config.SuperAdmin
+	if admin == nil || !admin.Enable || admin.Username == "" {
+		return nil
+	}
+
 	return &models.User{
 		ID:       admin.Username,
 		Username: admin.Username,
 		Realname: admin.Realname,
-		Password: admin.Password,
+		Password: admin.PasswordHash,
+		Status:   1,
+	}
+}
+
+// IsSuperAdmin tells whether the user id belongs to the enabled super admin
+func (a UserService) IsSuperAdmin(id string) bool {
+	admin := a.GetSuperAdmin()
+	return admin != nil && admin.ID == id
+}
+
+// newPasswordPolicy builds the password policy from the config and the blocklist file
+func newPasswordPolicy(config lib.Config, logger lib.Logger) *passwd.Policy {
+	conf := config.Password
+	if conf == nil {
+		return new(passwd.Policy)
+	}
+
+	policy := &passwd.Policy{
+		MinLength:        conf.MinLength,
+		RequireUppercase: conf.RequireUppercase,
+		RequireLowercase: conf.RequireLowercase,
+		RequireDigit:     conf.RequireDigit,
+		RequireSymbol:    conf.RequireSymbol,
+	}
+
+	policy.Block(conf.Blocklist...)
+	if conf.BlocklistFile != "" {
+		b, err := ioutil.ReadFile(conf.BlocklistFile)
+		if err != nil {
+			logger.Zap.Fatalf("Error to read password blocklist: %v", err)
+		}
+
+		policy.Block(strings.Split(string(b), "\n")...)
 	}
+
+	return policy
 }
 
 // WithTrx delegates transaction to repository database
 func (a UserService) WithTrx(trxHandle *gorm.DB) UserService {
 	a.userRepository = a.userRepository.WithTrx(trxHandle)
 	a.userRoleRepository = a.userRoleRepository.WithTrx(trxHandle)
+	a.userDepartmentRepository = a.userDepartmentRepository.WithTrx(trxHandle)
+	a.departmentRepository = a.departmentRepository.WithTrx(trxHandle)
+	a.departmentLeaderRepository = a.departmentLeaderRepository.WithTrx(trxHandle)
+	a.departmentService = a.departmentService.WithTrx(trxHandle)
+	a.historyRepository = a.historyRepository.WithTrx(trxHandle)
+	a.accessTokenService = a.accessTokenService.WithTrx(trxHandle)
+	a.identityRepository = a.identityRepository.WithTrx(trxHandle)
+	a.casbinService = a.casbinService.WithTrx(trxHandle)
 
 	return a
 }
 
 func (a UserService) Query(param *models.UserQueryParam) (userQR *models.UserQueryResult, err error) {
+	if v := param.DepartmentID; v != "" {
+		subtree, err := a.departmentService.SubtreeIDs(v)
+		if err != nil {
+			return nil, err
+		} else if len(subtree) == 0 {
+			// an unknown department has no users
+			subtree = []string{v}
+		}
+
+		param.DepartmentIDs = subtree
+	}
+
 	if userQR, err = a.userRepository.Query(param); err != nil {
 		return
 	}
@@ -83,43 +217,245 @@ func (a UserService) Query(param *models.UserQueryParam) (userQR *models.UserQue
 		return
 	}
 
+	uDepartmentQR, err := a.userDepartmentRepository.Query(
+		&models.UserDepartmentQueryParam{UserIDs: userQR.List.ToIDs()},
+	)
+
+	if err != nil {
+		return
+	}
+
 	m := uRoleQR.List.ToUserIDMap()
+	mDepartments := uDepartmentQR.List.ToUserIDMap()
 	for _, user := range userQR.List {
 		if uRoles, ok := m[user.ID]; ok {
 			user.UserRoles = uRoles
 		}
+
+		user.UserDepartments = mDepartments[user.ID]
 	}
 
 	return
 }
 
 func (a UserService) Verify(username, password string) (*models.User, error) {
-	// super admin user
-	admin := a.GetSuperAdmin()
-	if admin.Username == username && admin.Password == password {
+	// super admin user, its username is never looked up in the database
+	if admin := a.GetSuperAdmin(); admin != nil && admin.Username == username {
+		ok, _, err := a.passwords.Verify(password, admin.Password)
+		if err != nil {
+			a.logger.Zap.Errorf("Error to verify the password of the super admin, SuperAdmin.PasswordHash has to be a hash: %v", err)
+			return nil, errors.UserInvalidPassword
+		} else if !ok {
+			return nil, errors.UserInvalidPassword
+		}
+
 		return admin, nil
 	}
 
 	user, err := a.GetByUsername(username)
 	if err != nil {
+		if errors.Is(err, errors.UserRecordNotFound) && a.authenticator != nil {
+			return a.verifyExternal(nil, username, password)
+		}
+
 		return nil, err
+	} else if user.ServiceAccount {
+		return nil, errors.UserIsServiceAccount
+	} else if !user.IsLocal() {
+		return a.verifyExternal(user, username, password)
 	}
 
-	if user.Password != hash.SHA256(password) {
+	ok, rehash, err := a.passwords.Verify(password, user.Password)
+	if err != nil {
+		a.logger.Zap.Errorf("Error to verify the password of user %s: %v", user.Username, err)
+		return nil, errors.UserInvalidPassword
+	} else if !ok {
 		return nil, errors.UserInvalidPassword
 	} else if user.Status != 1 {
 		return nil, errors.UserIsDisable
 	}
 
+	// upgrade hashes of legacy algorithms or parameters while the password is known
+	if rehash {
+		if err := a.updatePassword(user, password); err != nil {
+			a.logger.Zap.Errorf("Error to rehash the password of user %s: %v", user.Username, err)
+		}
+	}
+
 	return user, nil
 }
 
+// verifyExternal verifies the password with the authenticator
+func (a UserService) verifyExternal(user *models.User, username, password string) (*models.User, error) {
+	if a.authenticator == nil || (user != nil && user.Source != a.authenticator.Source()) {
+		return nil, errors.UserInvalidPassword
+	}
+
+	identity, err := a.authenticator.Authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.SignInExternal(user, a.authenticator.Source(), identity, a.config.LDAP.GroupRoles)
+}
+
+// SignInExternal signs in a user verified by an external identity source,
+// the user is provisioned when it is nil and its mapped roles are synced on every login
+func (a UserService) SignInExternal(
+	user *models.User, source string, identity *Identity, mappings []*lib.GroupRoleConfig,
+) (*models.User, error) {
+	var err error
+	if user == nil {
+		if user, err = a.provision(source, identity); err != nil {
+			return nil, err
+		}
+	} else if user.Status != 1 {
+		return nil, errors.UserIsDisable
+	} else if err := a.syncProfile(user, identity); err != nil {
+		return nil, err
+	}
+
+	if err := a.syncRoles(user, identity.Groups, mappings); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (a UserService) provision(source string, identity *Identity) (*models.User, error) {
+	user := &models.User{
+		ID:        uuid.MustString(),
+		Username:  identity.Username,
+		Realname:  identity.Realname,
+		Email:     identity.Email,
+		Phone:     identity.Phone,
+		Status:    1,
+		Source:    source,
+		CreatedBy: source,
+	}
+
+	if user.Realname == "" {
+		user.Realname = user.Username
+	}
+
+	if err := a.Check(user); err != nil {
+		return nil, err
+	}
+
+	if err := a.userRepository.Create(user); err != nil {
+		return nil, err
+	}
+
+	a.logger.Zap.Infof("user %s is provisioned from %s", user.Username, user.Source)
+	return user, nil
+}
+
+// syncProfile takes over the profile fields the identity source has values for
+func (a UserService) syncProfile(user *models.User, identity *Identity) error {
+	profile := &models.UserProfile{Realname: user.Realname, Email: user.Email, Phone: user.Phone}
+	if identity.Realname != "" {
+		profile.Realname = identity.Realname
+	}
+	if identity.Email != "" {
+		profile.Email = identity.Email
+	}
+	if identity.Phone != "" {
+		profile.Phone = identity.Phone
+	}
+
+	if *profile == (models.UserProfile{Realname: user.Realname, Email: user.Email, Phone: user.Phone}) {
+		return nil
+	}
+
+	if err := a.userRepository.UpdateProfile(user.ID, profile); err != nil {
+		return err
+	}
+
+	user.Realname, user.Email, user.Phone = profile.Realname, profile.Email, profile.Phone
+	return nil
+}
+
+// syncRoles grants the roles mapped to the groups of the user and revokes the mapped roles
+// its groups no longer grant, roles that are not mapped keep their manual assignment
+func (a UserService) syncRoles(user *models.User, groups []string, mappings []*lib.GroupRoleConfig) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	memberOf := make(map[string]struct{})
+	for _, group := range groups {
+		memberOf[strings.ToLower(group)] = struct{}{}
+	}
+
+	var names []string
+	granted := make(map[string]struct{})
+	for _, item := range mappings {
+		names = append(names, item.Roles...)
+		if _, ok := memberOf[strings.ToLower(item.Group)]; ok {
+			for _, name := range item.Roles {
+				granted[name] = struct{}{}
+			}
+		}
+	}
+
+	roleQR, err := a.roleRepository.Query(&models.RoleQueryParam{Names: names})
+	if err != nil {
+		return err
+	}
+
+	current := user.UserRoles.ToMap()
+	changed := false
+
+	for _, role := range roleQR.List {
+		userRole, assigned := current[role.ID]
+		if _, ok := granted[role.Name]; ok && !assigned {
+			err = a.userRoleRepository.Create(&models.UserRole{
+				ID:     uuid.MustString(),
+				UserID: user.ID,
+				RoleID: role.ID,
+			})
+		} else if !ok && assigned {
+			err = a.userRoleRepository.Delete(userRole.ID)
+		} else {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		changed = true
+	}
+
+	if changed {
+		a.casbinService.UpdateUsers(user.ID)
+	}
+
+	return nil
+}
+
+func (a UserService) updatePassword(user *models.User, password string) error {
+	encoded, err := a.passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := a.userRepository.UpdatePassword(user.ID, encoded); err != nil {
+		return err
+	}
+
+	user.Password = encoded
+	return nil
+}
+
 func (a UserService) Check(user *models.User) error {
-	if user.Username == a.GetSuperAdmin().Username {
+	// the username stays reserved while the super admin is disabled
+	if admin := a.config.SuperAdmin; admin != nil && user.Username == admin.Username {
 		return errors.UserInvalidUsername
 	}
 
-	if qr, err := a.Query(&models.UserQueryParam{Username: user.Username}); err != nil {
+	// usernames are unique among all users, not only those of the data scope
+	if qr, err := a.userRepository.WithoutDataScope().Query(&models.UserQueryParam{Username: user.Username}); err != nil {
 		return err
 	} else if len(qr.List) > 0 {
 		return errors.UserAlreadyExists
@@ -129,7 +465,7 @@ func (a UserService) Check(user *models.User) error {
 }
 
 func (a UserService) GetUserInfo(ID string) (*models.UserInfo, error) {
-	if a.GetSuperAdmin().ID == ID {
+	if a.IsSuperAdmin(ID) {
 		user := a.GetSuperAdmin()
 		return &models.UserInfo{
 			ID:       user.Username,
@@ -147,6 +483,8 @@ func (a UserService) GetUserInfo(ID string) (*models.UserInfo, error) {
 		ID:       user.ID,
 		Username: user.Username,
 		Realname: user.Realname,
+		Email:    user.Email,
+		Phone:    user.Phone,
 	}
 
 	userRoleQR, err := a.userRoleRepository.Query(&models.UserRoleQueryParam{
@@ -173,8 +511,46 @@ func (a UserService) GetUserInfo(ID string) (*models.UserInfo, error) {
 	return userinfo, nil
 }
 
+// EffectiveRoleIDs returns the enabled roles of the ids and the enabled roles they inherit,
+// a disabled role grants nothing, neither its own menus nor the inherited ones
+func (a UserService) EffectiveRoleIDs(roleIDs []string) ([]string, error) {
+	var effective []string
+
+	seen := make(map[string]struct{})
+	for len(roleIDs) > 0 {
+		roleQR, err := a.roleRepository.Query(&models.RoleQueryParam{IDs: roleIDs, Status: 1})
+		if err != nil {
+			return nil, err
+		}
+
+		var enabled []string
+		for _, role := range roleQR.List {
+			if _, ok := seen[role.ID]; ok {
+				continue
+			}
+
+			seen[role.ID] = struct{}{}
+			enabled = append(enabled, role.ID)
+		}
+
+		if len(enabled) == 0 {
+			break
+		}
+
+		effective = append(effective, enabled...)
+		roleInheritQR, err := a.roleInheritRepository.Query(&models.RoleInheritQueryParam{RoleIDs: enabled})
+		if err != nil {
+			return nil, err
+		}
+
+		roleIDs = roleInheritQR.List.ToInheritIDs()
+	}
+
+	return effective, nil
+}
+
 func (a UserService) GetUserMenuTrees(ID string) (models.MenuTrees, error) {
-	if a.GetSuperAdmin().ID == ID {
+	if a.IsSuperAdmin(ID) {
 		menuQR, err := a.menuRepository.Query(&models.MenuQueryParam{
 			Status:     1,
 			OrderParam: dto.OrderParam{Key: "sequence", Direction: dto.OrderByASC},
@@ -202,8 +578,15 @@ func (a UserService) GetUserMenuTrees(ID string) (models.MenuTrees, error) {
 		return nil, errors.UserNoPermission
 	}
 
+	roleIDs, err := a.EffectiveRoleIDs(userRoleQR.List.ToRoleIDs())
+	if err != nil {
+		return nil, err
+	} else if len(roleIDs) == 0 {
+		return nil, errors.UserNoPermission
+	}
+
 	if roleMenuQR, err = a.roleMenuRepository.Query(&models.RoleMenuQueryParam{
-		RoleIDs: userRoleQR.List.ToRoleIDs(),
+		RoleIDs: roleIDs,
 	}); err != nil {
 		return nil, err
 	} else if len(roleMenuQR.List) == 0 {
@@ -287,18 +670,164 @@ func (a UserService) Get(id string) (*models.User, error) {
 		return nil, err
 	}
 
+	userDepartmentQR, err := a.userDepartmentRepository.Query(
+		&models.UserDepartmentQueryParam{UserID: id},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
 	user.UserRoles = userRoleQR.List
+	user.UserDepartments = userDepartmentQR.List
 	return user, nil
 }
 
+// CheckUserDepartments requires the departments to exist and exactly one of them to be primary
+func (a UserService) CheckUserDepartments(userDepartments models.UserDepartments) error {
+	if len(userDepartments) == 0 {
+		return nil
+	}
+
+	primary := 0
+	for _, userDepartment := range userDepartments.ToMap() {
+		if _, err := a.departmentRepository.Get(userDepartment.DepartmentID); err != nil {
+			return errors.Wrap(err, "department id")
+		}
+
+		if userDepartment.Primary {
+			primary++
+		}
+	}
+
+	if primary != 1 {
+		return errors.UserInvalidDepartment
+	}
+
+	return nil
+}
+
+// CheckDataScope keeps a user of a limited data scope from granting more than it reaches,
+// the added departments have to be in its scope and the added roles can not reach beyond it
+func (a UserService) CheckDataScope(userDepartments, aUserDepartments models.UserDepartments, aUserRoles models.UserRoles) error {
+	scope, err := a.userRepository.DataScope()
+	if err != nil {
+		return err
+	} else if scope == nil || (len(aUserDepartments) == 0 && len(aUserRoles) == 0) {
+		return nil
+	}
+
+	mScope := make(map[string]struct{}, len(scope.DepartmentIDs))
+	for _, id := range scope.DepartmentIDs {
+		mScope[id] = struct{}{}
+	}
+
+	within := func(ids []string) bool {
+		for _, id := range ids {
+			if _, ok := mScope[id]; !ok {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if !within(aUserDepartments.ToDepartmentIDs()) {
+		return errors.UserDepartmentOutOfDataScope
+	} else if len(aUserRoles) == 0 {
+		return nil
+	}
+
+	// the inherited roles add their scopes too
+	roleIDs, err := a.EffectiveRoleIDs(aUserRoles.ToRoleIDs())
+	if err != nil {
+		return err
+	} else if len(roleIDs) == 0 {
+		return nil
+	}
+
+	roleQR, err := a.roleRepository.Query(&models.RoleQueryParam{IDs: roleIDs})
+	if err != nil {
+		return err
+	}
+
+	var customRoleIDs []string
+	for _, role := range roleQR.List {
+		switch role.DataScope {
+		case constants.DataScopeAll, "":
+			return errors.UserRoleOutOfDataScope
+		case constants.DataScopeDepartment:
+			if !within(userDepartments.ToDepartmentIDs()) {
+				return errors.UserRoleOutOfDataScope
+			}
+		case constants.DataScopeDepartmentAndChildren:
+			subtree, err := a.departmentService.SubtreeIDs(userDepartments.ToDepartmentIDs()...)
+			if err != nil {
+				return err
+			} else if !within(subtree) {
+				return errors.UserRoleOutOfDataScope
+			}
+		case constants.DataScopeCustom:
+			customRoleIDs = append(customRoleIDs, role.ID)
+		}
+	}
+
+	if len(customRoleIDs) == 0 {
+		return nil
+	}
+
+	roleDepartmentQR, err := a.roleDepartmentRepository.Query(&models.RoleDepartmentQueryParam{RoleIDs: customRoleIDs})
+	if err != nil {
+		return err
+	}
+
+	for _, roleDepartment := range roleDepartmentQR.List {
+		if !within([]string{roleDepartment.DepartmentID}) {
+			return errors.UserRoleOutOfDataScope
+		}
+	}
+
+	return nil
+}
+
 func (a UserService) Create(user *models.User) (id string, err error) {
 	if err = a.Check(user); err != nil {
 		return
 	}
 
-	user.Password = hash.SHA256(user.Password)
+	// users of an external source are provisioned on their first login
+	user.Source = constants.UserSourceLocal
+
+	// service accounts have no password to sign in with
+	if user.ServiceAccount {
+		user.Password = ""
+	} else {
+		if err = a.policy.Validate(user.Password); err != nil {
+			return
+		}
+
+		if user.Password, err = a.passwords.Hash(user.Password); err != nil {
+			return
+		}
+
+		user.PasswordChangedAt = database.Datetime{Time: time.Now(), Valid: true}
+	}
+
+	if err = a.CheckUserDepartments(user.UserDepartments); err != nil {
+		return
+	}
+
+	if err = a.CheckDataScope(user.UserDepartments, user.UserDepartments, user.UserRoles); err != nil {
+		return
+	}
+
 	user.ID = uuid.MustString()
 
+	// two-factor authentication is only set up by the user
+	user.TwoFactorSecret = ""
+	user.TwoFactorEnabled = false
+	user.LastLoginAt, user.LastLoginIP = database.Datetime{}, ""
+
 	for _, userRole := range user.UserRoles {
 		userRole.ID = uuid.MustString()
 		userRole.UserID = user.ID
@@ -308,11 +837,26 @@ func (a UserService) Create(user *models.User) (id string, err error) {
 		}
 	}
 
+	for _, userDepartment := range user.UserDepartments.ToMap() {
+		userDepartment.ID = uuid.MustString()
+		userDepartment.UserID = user.ID
+
+		if err = a.userDepartmentRepository.Create(userDepartment); err != nil {
+			return
+		}
+	}
+
 	if err = a.userRepository.Create(user); err != nil {
 		return
 	}
 
-	a.casbinService.Enforcer.LoadPolicy()
+	if !user.ServiceAccount {
+		if err = a.recordPassword(user.ID, user.Password); err != nil {
+			return
+		}
+	}
+
+	a.casbinService.UpdateUsers(user.ID)
 	return user.ID, nil
 }
 
@@ -326,17 +870,52 @@ func (a UserService) Update(id string, user *models.User) error {
 		}
 	}
 
-	if user.Password != "" {
-		user.Password = hash.SHA256(user.Password)
+	// an account cannot be turned into a service account or moved to another source
+	user.ServiceAccount = oUser.ServiceAccount
+	user.Source = oUser.Source
+
+	changed := user.Password != "" && !oUser.ServiceAccount && oUser.IsLocal()
+	if changed {
+		if err := a.CheckPassword(oUser, user.Password); err != nil {
+			return err
+		}
+
+		if user.Password, err = a.passwords.Hash(user.Password); err != nil {
+			return err
+		}
+
+		user.PasswordChangedAt = database.Datetime{Time: time.Now(), Valid: true}
 	} else {
 		user.Password = oUser.Password
+		user.PasswordChangedAt = oUser.PasswordChangedAt
 	}
 
 	user.ID = oUser.ID
 	user.CreatedBy = oUser.CreatedBy
 	user.CreatedAt = oUser.CreatedAt
+	user.TwoFactorSecret = oUser.TwoFactorSecret
+	user.TwoFactorEnabled = oUser.TwoFactorEnabled
+	user.LastLoginAt = oUser.LastLoginAt
+	user.LastLoginIP = oUser.LastLoginIP
+
+	if err := a.CheckUserDepartments(user.UserDepartments); err != nil {
+		return err
+	}
 
 	aUserRoles, dUserRoles := a.CompareUserRoles(oUser.UserRoles, user.UserRoles)
+	aUserDepartments, dUserDepartments, uUserDepartments := a.CompareUserDepartments(
+		oUser.UserDepartments, user.UserDepartments,
+	)
+
+	if err := a.CheckDataScope(user.UserDepartments, aUserDepartments, aUserRoles); err != nil {
+		return err
+	}
+
+	// the data scope reaches the user by its departments, it is updated before they change
+	if err := a.userRepository.Update(id, user); err != nil {
+		return err
+	}
+
 	for _, aUserRole := range aUserRoles {
 		aUserRole.ID = uuid.MustString()
 		aUserRole.UserID = id
@@ -351,11 +930,40 @@ func (a UserService) Update(id string, user *models.User) error {
 		}
 	}
 
-	if err := a.userRepository.Update(id, user); err != nil {
-		return err
+	for _, aUserDepartment := range aUserDepartments {
+		aUserDepartment.ID = uuid.MustString()
+		aUserDepartment.UserID = id
+		if err := a.userDepartmentRepository.Create(aUserDepartment); err != nil {
+			return err
+		}
 	}
 
-	a.casbinService.Enforcer.LoadPolicy()
+	for _, dUserDepartment := range dUserDepartments {
+		if err := a.userDepartmentRepository.Delete(dUserDepartment.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, uUserDepartment := range uUserDepartments {
+		if err := a.userDepartmentRepository.Update(uUserDepartment.ID, uUserDepartment); err != nil {
+			return err
+		}
+	}
+
+	if changed {
+		if err := a.recordPassword(id, user.Password); err != nil {
+			return err
+		}
+	}
+
+	// a disabled user or a password set by an admin logs the user out everywhere
+	if user.Status != 1 || changed {
+		if err := a.authService.DestroyUserSessions(id); err != nil {
+			return err
+		}
+	}
+
+	a.casbinService.UpdateUsers(id)
 	return nil
 }
 
@@ -365,12 +973,37 @@ func (a UserService) Delete(id string) error {
 		return err
 	}
 
+	// the data scope reaches the user by its departments, it is deleted before them
+	if err := a.userRepository.Delete(id); err != nil {
+		return err
+	}
+
 	if err := a.userRoleRepository.DeleteByUserID(id); err != nil {
 		return err
 	}
 
-	a.casbinService.Enforcer.LoadPolicy()
-	return a.userRepository.Delete(id)
+	if err := a.userDepartmentRepository.DeleteByUserID(id); err != nil {
+		return err
+	}
+
+	if err := a.departmentLeaderRepository.DeleteByUserID(id); err != nil {
+		return err
+	}
+
+	if err := a.accessTokenService.DeleteByUserID(id, "user deleted"); err != nil {
+		return err
+	}
+
+	if err := a.identityRepository.DeleteByUserID(id); err != nil {
+		return err
+	}
+
+	if err := a.authService.DestroyUserSessions(id); err != nil {
+		return err
+	}
+
+	a.casbinService.UpdateUsers(id)
+	return nil
 }
 
 func (a UserService) UpdateStatus(id string, status int) error {
@@ -383,10 +1016,171 @@ func (a UserService) UpdateStatus(id string, status int) error {
 		return err
 	}
 
-	a.casbinService.Enforcer.LoadPolicy()
+	// a disabled user is logged out immediately
+	if status != 1 {
+		if err = a.authService.DestroyUserSessions(id); err != nil {
+			return err
+		}
+	}
+
+	a.casbinService.UpdateUsers(id)
 	return nil
 }
 
+// CheckPassword validates a new password of the user against the policy and the password history
+func (a UserService) CheckPassword(user *models.User, password string) error {
+	if err := a.policy.Validate(password); err != nil {
+		return err
+	}
+
+	size := 0
+	if conf := a.config.Password; conf != nil {
+		size = conf.HistorySize
+	}
+
+	if size <= 0 {
+		return nil
+	}
+
+	hashes := make([]string, 0, size+1)
+	if user.Password != "" {
+		hashes = append(hashes, user.Password)
+	}
+
+	historyQR, err := a.historyRepository.Query(&models.UserPasswordHistoryQueryParam{
+		PaginationParam: dto.PaginationParam{PageSize: size},
+		UserID:          user.ID,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, history := range historyQR.List {
+		hashes = append(hashes, history.Password)
+	}
+
+	for _, encoded := range hashes {
+		if ok, _, _ := a.passwords.Verify(password, encoded); ok {
+			return errors.UserPasswordReused
+		}
+	}
+
+	return nil
+}
+
+// recordPassword keeps the hash in the password history, pruned to the history size
+func (a UserService) recordPassword(userID, encoded string) error {
+	size := 0
+	if conf := a.config.Password; conf != nil {
+		size = conf.HistorySize
+	}
+
+	if size <= 0 {
+		return nil
+	}
+
+	err := a.historyRepository.Create(&models.UserPasswordHistory{
+		ID:       uuid.MustString(),
+		UserID:   userID,
+		Password: encoded,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	historyQR, err := a.historyRepository.Query(&models.UserPasswordHistoryQueryParam{
+		PaginationParam: dto.PaginationParam{PageSize: size},
+		UserID:          userID,
+	})
+
+	if err != nil {
+		return err
+	} else if len(historyQR.List) < size {
+		return nil
+	}
+
+	return a.historyRepository.DeleteBefore(userID, historyQR.List[len(historyQR.List)-1].RecordID)
+}
+
+// PasswordExpired tells whether the password of the user is older than the max age
+func (a UserService) PasswordExpired(user *models.User) bool {
+	conf := a.config.Password
+	if conf == nil || conf.MaxAge <= 0 || !user.IsLocal() {
+		return false
+	}
+
+	// users created before the expiry was tracked count from their creation
+	changedAt := user.PasswordChangedAt
+	if !changedAt.Valid {
+		changedAt = user.CreatedAt
+	}
+
+	if !changedAt.Valid {
+		return false
+	}
+
+	return time.Since(changedAt.Time) > time.Duration(conf.MaxAge)*24*time.Hour
+}
+
+// UpdateProfile updates the fields the user can edit on its own
+func (a UserService) UpdateProfile(id string, profile *models.UserProfile) error {
+	if _, err := a.userRepository.Get(id); err != nil {
+		return err
+	}
+
+	return a.userRepository.UpdateProfile(id, profile)
+}
+
+// ChangePassword replaces the password of the user after checking the old one
+func (a UserService) ChangePassword(id, oldPassword, newPassword string) error {
+	user, err := a.userRepository.Get(id)
+	if err != nil {
+		return err
+	} else if !user.IsLocal() {
+		return errors.UserIsExternal
+	}
+
+	if ok, _, err := a.passwords.Verify(oldPassword, user.Password); err != nil || !ok {
+		return errors.UserInvalidPassword
+	}
+
+	return a.setPassword(user, newPassword)
+}
+
+// ResetPassword replaces the password of the user without the old one,
+// the caller has to verify the user by other means
+func (a UserService) ResetPassword(id, password string) error {
+	user, err := a.userRepository.Get(id)
+	if err != nil {
+		return err
+	}
+
+	return a.setPassword(user, password)
+}
+
+func (a UserService) setPassword(user *models.User, password string) error {
+	if !user.IsLocal() {
+		return errors.UserIsExternal
+	}
+
+	if err := a.CheckPassword(user, password); err != nil {
+		return err
+	}
+
+	encoded, err := a.passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := a.userRepository.ChangePassword(user.ID, encoded); err != nil {
+		return err
+	}
+
+	return a.recordPassword(user.ID, encoded)
+}
+
 func (a UserService) CompareUserRoles(oUserRoles, nUserRoles models.UserRoles) (aList, dList models.UserRoles) {
 	oMap := oUserRoles.ToMap()
 	nMap := nUserRoles.ToMap()
@@ -406,3 +1200,29 @@ func (a UserService) CompareUserRoles(oUserRoles, nUserRoles models.UserRoles) (
 
 	return
 }
+
+// CompareUserDepartments also returns the kept departments whose primary flag changed, with the ids of the old records
+func (a UserService) CompareUserDepartments(oUserDepartments, nUserDepartments models.UserDepartments) (aList, dList, uList models.UserDepartments) {
+	oMap := oUserDepartments.ToMap()
+	nMap := nUserDepartments.ToMap()
+
+	for k, nUserDepartment := range nMap {
+		if oUserDepartment, ok := oMap[k]; ok {
+			if oUserDepartment.Primary != nUserDepartment.Primary {
+				oUserDepartment.Primary = nUserDepartment.Primary
+				uList = append(uList, oUserDepartment)
+			}
+
+			delete(oMap, k)
+			continue
+		}
+
+		aList = append(aList, nUserDepartment)
+	}
+
+	for _, oUserDepartment := range oMap {
+		dList = append(dList, oUserDepartment)
+	}
+
+	return
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 
+	"github.com/RealLiuSha/echo-admin/cmd/hashpasswd"
 	"github.com/RealLiuSha/echo-admin/cmd/migrate"
 	"github.com/RealLiuSha/echo-admin/cmd/runserver"
 	"github.com/RealLiuSha/echo-admin/cmd/setup"
@@ -14,6 +15,7 @@ func init() {
 	rootCmd.AddCommand(runserver.StartCmd)
 	rootCmd.AddCommand(migrate.StartCmd)
 	rootCmd.AddCommand(setup.StartCmd)
+	rootCmd.AddCommand(hashpasswd.StartCmd)
 }
 
 var rootCmd = &cobra.Command{
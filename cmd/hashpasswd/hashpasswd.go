@@ -0,0 +1,55 @@
+package hashpasswd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/RealLiuSha/echo-admin/api/services"
+	"github.com/RealLiuSha/echo-admin/lib"
+)
+
+var configFile string
+
+func init() {
+	pf := StartCmd.PersistentFlags()
+	pf.StringVarP(&configFile, "config", "c",
+		"config/config.yaml", "this parameter is used to start the service application")
+
+	cobra.MarkFlagRequired(pf, "config")
+}
+
+var StartCmd = &cobra.Command{
+	Use:          "hashpasswd",
+	Short:        "Hash a password read from stdin, e.g. for SuperAdmin.PasswordHash",
+	Example:      "{execfile} hashpasswd -c config/config.yaml",
+	SilenceUsage: true,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		lib.SetConfigPath(configFile)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		config := lib.NewConfig()
+		logger := lib.NewLogger(config)
+
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && password == "" {
+			logger.Zap.Fatalf("Error to read the password: %v", err)
+		}
+
+		password = strings.TrimRight(password, "\r\n")
+		if password == "" {
+			logger.Zap.Fatal("password is empty")
+		}
+
+		encoded, err := services.NewPasswords(config, logger).Hash(password)
+		if err != nil {
+			logger.Zap.Fatalf("Error to hash the password: %v", err)
+		}
+
+		fmt.Println(encoded)
+	},
+}
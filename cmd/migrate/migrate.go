@@ -34,9 +34,19 @@ var StartCmd = &cobra.Command{
 			&models.UserRole{},
 			&models.Role{},
 			&models.RoleMenu{},
+			&models.RoleInherit{},
 			&models.Menu{},
 			&models.MenuAction{},
 			&models.MenuActionResource{},
+			&models.UserRecoveryCode{},
+			&models.UserPasswordHistory{},
+			&models.AccessToken{},
+			&models.UserIdentity{},
+			&models.LoginLog{},
+			&models.Department{},
+			&models.DepartmentLeader{},
+			&models.UserDepartment{},
+			&models.RoleDepartment{},
 		); err != nil {
 			logger.Zap.Fatalf("Error to migrate database: %v", err)
 		}
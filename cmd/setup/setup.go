@@ -46,6 +46,8 @@ var StartCmd = &cobra.Command{
 			repository.NewMenuRepository(db, logger),
 			repository.NewMenuActionRepository(db, logger),
 			repository.NewMenuActionResourceRepository(db, logger),
+			// menus are only created, no role holds their actions yet
+			services.CasbinService{},
 		)
 
 		if !file.IsFile(menuFile) {
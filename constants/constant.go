@@ -14,6 +14,41 @@ const CaptchaExpireTimes = 90
 const CurrentUser = "current-user"
 const RoutesCacheKey = "routes"
 
+// session restrictions, a restricted session only reaches the paths that lift the restriction
+const SessionRestrictTwoFactor = "two_factor"
+const SessionRestrictPassword = "password"
+
+// access tokens start with the prefix, so that they are told apart from jwt
+const AccessTokenPrefix = "eat_"
+
+// access token scopes, read only allows the safe http methods
+const AccessTokenScopeRead = "read"
+const AccessTokenScopeWrite = "write"
+
+// user sources, the password of an external user is verified by its authenticator
+const UserSourceLocal = "local"
+const UserSourceLDAP = "ldap"
+const UserSourceOIDC = "oidc"
+
+// login methods of the login log
+const LoginMethodPassword = "password"
+const LoginMethodTwoFactor = "2fa"
+const LoginMethodOIDC = "oidc"
+
+// response header of the requests of an impersonation session, the username of the admin
+const HeaderImpersonatedBy = "X-Impersonated-By"
+
+// auth modes, how the client keeps the tokens
+const AuthModeHeader = "header"
+const AuthModeCookie = "cookie"
+
+// data scopes of the roles, the rows of the users a role reaches
+const DataScopeAll = "all"
+const DataScopeDepartment = "department"
+const DataScopeDepartmentAndChildren = "department_and_children"
+const DataScopeSelf = "self"
+const DataScopeCustom = "custom"
+
 // RedisDB
 const RedisMainDB = 0
 const RedisTaskDB = 1
\ No newline at end of file
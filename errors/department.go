@@ -0,0 +1,10 @@
+package errors
+
+var (
+	DepartmentRecordNotFound          = New("department record not found")
+	DepartmentAlreadyExists           = New("department already exists")
+	DepartmentInvalidParent           = New("department invalid parent")
+	DepartmentNotAllowDeleteWithChild = New("contains children, cannot be deleted")
+	DepartmentNotAllowDeleteWithUser  = New("contains users, cannot be deleted")
+	DepartmentInvalidLeader           = New("department leader is not a user")
+)
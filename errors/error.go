@@ -32,6 +32,7 @@ var (
 // Captcha
 var (
 	CaptchaAnswerCodeNoMatch = errors.New("captcha answer code no match")
+	CaptchaRequired          = errors.New("captcha is required")
 )
 
 // Auth
@@ -41,4 +42,44 @@ var (
 	AuthTokenNotValidYet  = errors.New("auth token not active yet")
 	AuthTokenMalformed    = errors.New("auth token is malformed")
 	AuthTokenGenerateFail = errors.New("failed to generate auth token")
+	AuthTokenRevoked      = errors.New("auth token has been revoked")
+
+	AuthRefreshTokenInvalid = errors.New("refresh token is invalid")
+	AuthRefreshTokenReused  = errors.New("refresh token has already been used")
+
+	AuthSessionNotFound   = errors.New("auth session not found")
+	AuthSessionRestricted = errors.New("auth session is restricted, complete the required steps first")
+	AuthLoginThrottled    = errors.New("too many failed logins, retry later")
+	AuthTokenScopeDenied  = errors.New("auth token scopes do not allow the request")
+
+	AuthImpersonationDenied   = errors.New("impersonation sessions cannot manage the account of the user")
+	AuthImpersonationReadOnly = errors.New("impersonation sessions are read only")
+
+	AuthCSRFTokenInvalid = errors.New("csrf token is missing or does not match")
+)
+
+// OIDC
+var (
+	OIDCNotEnabled   = errors.New("oidc login is not enabled")
+	OIDCStateInvalid = errors.New("oidc login state is invalid or expired")
+	OIDCNoUsername   = errors.New("oidc id token has no username claim")
+)
+
+// AccessToken
+var (
+	AccessTokenNotFound       = errors.New("access token not found")
+	AccessTokenNotAllowed     = errors.New("access tokens can only be created for service accounts")
+	AccessTokenInvalidName    = errors.New("access token name is required and at most 64 characters")
+	AccessTokenInvalidScopes  = errors.New("access token scopes must be read or write")
+	AccessTokenInvalidExpires = errors.New("access token expiry can not be negative")
+)
+
+// TwoFactor
+var (
+	TwoFactorCodeInvalid      = errors.New("two-factor code is invalid")
+	TwoFactorChallengeInvalid = errors.New("two-factor challenge is invalid or expired")
+	TwoFactorNotEnrolled      = errors.New("two-factor authentication is not enrolled")
+	TwoFactorNotEnabled       = errors.New("two-factor authentication is not enabled")
+	TwoFactorAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	TwoFactorRequired         = errors.New("two-factor authentication is required by the user roles")
 )
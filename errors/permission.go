@@ -0,0 +1,6 @@
+package errors
+
+var (
+	PermissionTooManyChecks = New("too many permission checks in one request")
+	PermissionInvalidCheck  = New("permission check is empty")
+)
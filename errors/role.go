@@ -1,8 +1,12 @@
 package errors
 
 var (
-	RoleRecordNotFound         = New("role record not found")
-	RoleIsDisable              = New("role is disabled")
-	RoleAlreadyExists          = New("role already exists")
-	RoleNotAllowDeleteWithUser = New("used by users, cannot be deleted")
+	RoleRecordNotFound          = New("role record not found")
+	RoleIsDisable               = New("role is disabled")
+	RoleAlreadyExists           = New("role already exists")
+	RoleNotAllowDeleteWithUser  = New("used by users, cannot be deleted")
+	RoleNotAllowDeleteInherited = New("inherited by other roles, cannot be deleted")
+	RoleInheritCycle            = New("role inheritance cannot be cyclic")
+	RoleInheritTooDeep          = New("role inheritance is too deep")
+	RoleInvalidDataScope        = New("invalid role data scope")
 )
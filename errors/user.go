@@ -1,11 +1,20 @@
 package errors
 
 var (
-	UserRecordNotFound   = New("user record not found")
-	UserInvalidPassword  = New("invalid user password")
-	UserIsDisable        = New("user is disabled")
-	UserPasswordRequired = New("user password is required")
-	UserInvalidUsername  = New("invalid username")
-	UserAlreadyExists    = New("user already exists")
-	UserNoPermission     = New("user no permission")
+	UserRecordNotFound           = New("user record not found")
+	UserInvalidPassword          = New("invalid user password")
+	UserIsDisable                = New("user is disabled")
+	UserPasswordRequired         = New("user password is required")
+	UserInvalidUsername          = New("invalid username")
+	UserAlreadyExists            = New("user already exists")
+	UserNoPermission             = New("user no permission")
+	UserPasswordReused           = New("user password was used recently")
+	UserIsLocked                 = New("user is locked after too many failed logins")
+	UserResetTokenInvalid        = New("password reset token is invalid or expired")
+	UserIsServiceAccount         = New("service account can only authenticate with access tokens")
+	UserIsExternal               = New("user password is managed by an external directory")
+	UserNotImpersonable          = New("user cannot be impersonated")
+	UserInvalidDepartment        = New("user has to belong to exactly one primary department")
+	UserDepartmentOutOfDataScope = New("department is out of the data scope of the current user")
+	UserRoleOutOfDataScope       = New("role reaches beyond the data scope of the current user")
 )
@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/RealLiuSha/echo-admin/constants"
+	"github.com/RealLiuSha/echo-admin/pkg/random"
+)
+
+// the refresh token cookie is only sent to the refresh api
+const refreshCookiePath = "/api/v1/publics/user/refresh"
+
+// the oidc state cookie is only sent to the callback of the provider
+const (
+	oidcStateCookieName = "oidc_state"
+	oidcStateCookiePath = "/api/v1/publics/oidc/callback"
+)
+
+// AuthCookie keeps the tokens in HttpOnly cookies when Auth.Mode is cookie,
+// requests authenticated by the cookie have to repeat the csrf cookie in a header (double submit)
+type AuthCookie struct {
+	enabled        bool
+	refreshExpired int
+	config         AuthCookieConfig
+	sameSite       http.SameSite
+}
+
+// NewAuthCookie creates the auth cookies of the configured mode
+func NewAuthCookie(config Config, logger Logger) AuthCookie {
+	cookie := AuthCookie{
+		enabled:        config.Auth.Mode == constants.AuthModeCookie,
+		refreshExpired: config.Auth.RefreshTokenExpired,
+	}
+
+	if conf := config.Auth.Cookie; conf != nil {
+		cookie.config = *conf
+	}
+
+	if cookie.config.Name == "" {
+		cookie.config.Name = "access_token"
+	}
+	if cookie.config.RefreshName == "" {
+		cookie.config.RefreshName = "refresh_token"
+	}
+	if cookie.config.CSRFName == "" {
+		cookie.config.CSRFName = "csrf_token"
+	}
+	if cookie.config.CSRFHeader == "" {
+		cookie.config.CSRFHeader = "X-CSRF-Token"
+	}
+
+	switch strings.ToLower(cookie.config.SameSite) {
+	case "", "strict":
+		cookie.sameSite = http.SameSiteStrictMode
+	case "lax":
+		cookie.sameSite = http.SameSiteLaxMode
+	case "none":
+		cookie.sameSite = http.SameSiteNoneMode
+		if !cookie.config.Secure {
+			logger.Zap.Warn("Auth.Cookie.SameSite none is refused by browsers without Auth.Cookie.Secure")
+		}
+	default:
+		logger.Zap.Fatalf("Error to set up auth cookies: unsupported SameSite %s", cookie.config.SameSite)
+	}
+
+	return cookie
+}
+
+// Enabled tells whether the tokens are kept in cookies
+func (a AuthCookie) Enabled() bool {
+	return a.enabled
+}
+
+// SetTokens sets the token cookies and a new csrf token,
+// the cookies live as long as the session so that the refresh token outlives the access token
+func (a AuthCookie) SetTokens(ctx echo.Context, token string, expiresIn int, refreshToken string) {
+	maxAge := expiresIn
+	if refreshToken != "" && a.refreshExpired > maxAge {
+		maxAge = a.refreshExpired
+	}
+
+	ctx.SetCookie(a.cookie(a.config.Name, token, "/", maxAge, true))
+	ctx.SetCookie(a.cookie(a.config.CSRFName, random.Token(32), "/", maxAge, false))
+
+	if refreshToken != "" {
+		ctx.SetCookie(a.cookie(a.config.RefreshName, refreshToken, refreshCookiePath, maxAge, true))
+	}
+}
+
+// Clear removes the cookies of the tokens
+func (a AuthCookie) Clear(ctx echo.Context) {
+	ctx.SetCookie(a.cookie(a.config.Name, "", "/", -1, true))
+	ctx.SetCookie(a.cookie(a.config.CSRFName, "", "/", -1, false))
+	ctx.SetCookie(a.cookie(a.config.RefreshName, "", refreshCookiePath, -1, true))
+}
+
+// Token returns the access token of the cookie
+func (a AuthCookie) Token(ctx echo.Context) string {
+	return a.value(ctx, a.config.Name)
+}
+
+// RefreshToken returns the refresh token of the cookie
+func (a AuthCookie) RefreshToken(ctx echo.Context) string {
+	return a.value(ctx, a.config.RefreshName)
+}
+
+// VerifyCSRF tells whether the csrf header repeats the csrf cookie
+func (a AuthCookie) VerifyCSRF(ctx echo.Context) bool {
+	cookie := a.value(ctx, a.config.CSRFName)
+	header := ctx.Request().Header.Get(a.config.CSRFHeader)
+
+	return cookie != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+// SetOIDCState binds an oidc login to the browser that started it, in every auth mode,
+// the cookie is Lax so that it comes back with the redirect of the provider
+func (a AuthCookie) SetOIDCState(ctx echo.Context, value string) {
+	ctx.SetCookie(a.oidcStateCookie(value, 0))
+}
+
+// OIDCState returns the oidc state cookie and removes it, it is only good for one callback
+func (a AuthCookie) OIDCState(ctx echo.Context) string {
+	value := a.value(ctx, oidcStateCookieName)
+	if value != "" {
+		ctx.SetCookie(a.oidcStateCookie("", -1))
+	}
+
+	return value
+}
+
+func (a AuthCookie) oidcStateCookie(value string, maxAge int) *http.Cookie {
+	cookie := a.cookie(oidcStateCookieName, value, oidcStateCookiePath, maxAge, true)
+	cookie.SameSite = http.SameSiteLaxMode
+
+	return cookie
+}
+
+func (a AuthCookie) value(ctx echo.Context, name string) string {
+	cookie, err := ctx.Cookie(name)
+	if err != nil {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+func (a AuthCookie) cookie(name, value, path string, maxAge int, httpOnly bool) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   a.config.Domain,
+		MaxAge:   maxAge,
+		Secure:   a.config.Secure,
+		HttpOnly: httpOnly,
+		SameSite: a.sameSite,
+	}
+
+	if maxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(maxAge) * time.Second)
+	}
+
+	return cookie
+}
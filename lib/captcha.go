@@ -1,17 +1,24 @@
 package lib
 
 import (
+	"fmt"
 	"image/color"
+	"net"
+	"strings"
 	"time"
 
+	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
 	"github.com/RealLiuSha/echo-admin/constants"
+	"github.com/RealLiuSha/echo-admin/errors"
 	"github.com/mojocn/base64Captcha"
 )
 
 type Captcha struct {
 	*base64Captcha.Captcha
+	requireAfter int
+	trusted      []*net.IPNet
 }
 
 type CaptchaStore struct {
@@ -20,26 +27,152 @@ type CaptchaStore struct {
 	logger *zap.SugaredLogger
 }
 
-func NewCaptcha(redis Redis, logger Logger) Captcha {
-	ds := base64Captcha.NewDriverString(
-		46,
-		140,
-		2,
-		2,
-		4,
-		"234567890abcdefghjkmnpqrstuvwxyz",
-		&color.RGBA{R: 240, G: 240, B: 246, A: 246},
-		[]string{"wqy-microhei.ttc"},
-	)
+func NewCaptcha(config Config, redis Redis, logger Logger) Captcha {
+	conf := config.Captcha
+	if conf == nil {
+		conf = &CaptchaConfig{}
+	}
+
+	driver, err := newCaptchaDriver(conf)
+	if err != nil {
+		logger.Zap.Fatalf("Error to set up captcha: %v", err)
+	}
+
+	trusted, err := parseTrustedCIDRs(conf.TrustedCIDRs)
+	if err != nil {
+		logger.Zap.Fatalf("Error to set up captcha: %v", err)
+	}
 
-	driver := ds.ConvertFonts()
 	store := CaptchaStore{
 		redis:  &redis,
 		key:    constants.CaptchaKeyPrefix,
 		logger: logger.Zap.With(zap.String("module", "captcha")),
 	}
 
-	return Captcha{Captcha: base64Captcha.NewCaptcha(driver, store)}
+	return Captcha{
+		Captcha:      base64Captcha.NewCaptcha(driver, store),
+		requireAfter: conf.RequireAfter,
+		trusted:      trusted,
+	}
+}
+
+func newCaptchaDriver(conf *CaptchaConfig) (base64Captcha.Driver, error) {
+	height, width, length := conf.Height, conf.Width, conf.Length
+	if height <= 0 {
+		height = 46
+	}
+	if width <= 0 {
+		width = 140
+	}
+
+	bgColor := &color.RGBA{R: 240, G: 240, B: 246, A: 246}
+	fonts := []string{"wqy-microhei.ttc"}
+
+	switch strings.ToLower(conf.Driver) {
+	case "", "string":
+		if length <= 0 {
+			length = 4
+		}
+
+		source := conf.Source
+		if source == "" {
+			source = "234567890abcdefghjkmnpqrstuvwxyz"
+		}
+
+		return base64Captcha.NewDriverString(
+			height, width, conf.NoiseCount, conf.ShowLineOptions, length, source, bgColor, fonts,
+		).ConvertFonts(), nil
+	case "digit":
+		if length <= 0 {
+			length = 5
+		}
+
+		return base64Captcha.NewDriverDigit(height, width, length, 0.7, 80), nil
+	case "math":
+		return base64Captcha.NewDriverMath(
+			height, width, conf.NoiseCount, conf.ShowLineOptions, bgColor, fonts,
+		).ConvertFonts(), nil
+	case "audio":
+		if length <= 0 {
+			length = 6
+		}
+
+		language := conf.Language
+		if language == "" {
+			language = "en"
+		}
+
+		return base64Captcha.NewDriverAudio(length, language), nil
+	}
+
+	return nil, fmt.Errorf("unsupported driver %s", conf.Driver)
+}
+
+// parseTrustedCIDRs accepts networks and single addresses
+func parseTrustedCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	trusted := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted address %s", cidr)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid trusted network %s", cidr)
+		}
+
+		trusted = append(trusted, network)
+	}
+
+	return trusted, nil
+}
+
+// Trusted tells whether the client belongs to a trusted network that skips captchas,
+// X-Forwarded-For only names the client behind the trusted proxies of Http.TrustedProxies
+func (a Captcha) Trusted(ctx echo.Context) bool {
+	addr := net.ParseIP(ctx.RealIP())
+	if addr == nil {
+		return false
+	}
+
+	for _, network := range a.trusted {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Required tells whether a login has to solve a captcha after the given failed logins
+func (a Captcha) Required(ctx echo.Context, failures int64) bool {
+	if a.Trusted(ctx) {
+		return false
+	}
+
+	return failures >= int64(a.requireAfter)
+}
+
+// Check verifies the answer without spending a right one, so that it can still be submitted,
+// a wrong answer spends the captcha to stop guessing
+func (a Captcha) Check(id, answer string) bool {
+	if a.Verify(id, answer, false) {
+		return true
+	}
+
+	a.Store.Get(id, true)
+	return false
 }
 
 func (a CaptchaStore) getKey(v string) string {
@@ -53,22 +186,30 @@ func (a CaptchaStore) Set(id string, value string) {
 	}
 }
 
+// Get returns the answer of the captcha, clear spends it,
+// only the caller that deletes it gets the answer so that it is used once
 func (a CaptchaStore) Get(id string, clear bool) string {
 	var (
 		key = a.getKey(id)
 		val string
 	)
 
-	err := a.redis.Get(key, &val)
+	err := a.redis.GetSkippingLocalCache(key, &val)
 	if err != nil {
-		a.logger.Errorf("captcha - error reading redis: %v", err)
+		if !errors.Is(err, errors.RedisKeyNoExist) {
+			a.logger.Errorf("captcha - error reading redis: %v", err)
+		}
+
 		return ""
 	}
 
-	if !clear {
-		_, err := a.redis.Delete(key)
+	if clear {
+		ok, err := a.redis.Delete(key)
 		if err != nil {
 			a.logger.Errorf("captcha - error deleting item from redis: %v", err)
+			return ""
+		} else if !ok {
+			return ""
 		}
 	}
 
@@ -77,5 +218,5 @@ func (a CaptchaStore) Get(id string, clear bool) string {
 
 func (a CaptchaStore) Verify(id, answer string, clear bool) bool {
 	v := a.Get(id, clear)
-	return v == answer
+	return v != "" && v == answer
 }
@@ -23,10 +23,24 @@ var defaultConfig = Config{
 		Directory:   "/tmp/app",
 		Development: true,
 	},
-	SuperAdmin: &SuperAdminConfig{},
-	Auth:       &AuthConfig{},
-	Casbin:     &CasbinConfig{Enable: false},
-	Redis:      &RedisConfig{Host: "127.0.0.1", Port: 6379},
+	SuperAdmin: &SuperAdminConfig{Enable: false},
+	Auth: &AuthConfig{
+		TwoFactor:  &TwoFactorConfig{},
+		LoginGuard: &LoginGuardConfig{},
+		Impersonation: &ImpersonationConfig{
+			Expired:  900,
+			ReadOnly: true,
+		},
+		Mode:   "header",
+		Cookie: &AuthCookieConfig{},
+	},
+	Password: &PasswordConfig{Algorithm: "argon2id"},
+	Captcha:  &CaptchaConfig{Driver: "string"},
+	LDAP:     &LDAPConfig{Enable: false},
+	OIDC:     &OIDCConfig{Enable: false},
+	Notifier: &NotifierConfig{Driver: "log"},
+	Casbin:   &CasbinConfig{Enable: false},
+	Redis:    &RedisConfig{Host: "127.0.0.1", Port: 6379},
 	Database: &DatabaseConfig{
 		Parameters:   "charset=utf8mb4&parseTime=True&loc=Local&allowNativePasswords=true&timeout=5s",
 		MaxLifetime:  7200,
@@ -74,14 +88,22 @@ type Config struct {
 	Log        *LogConfig        `mapstructure:"Log"`
 	SuperAdmin *SuperAdminConfig `mapstructure:"SuperAdmin"`
 	Auth       *AuthConfig       `mapstructure:"Auth"`
+	Password   *PasswordConfig   `mapstructure:"Password"`
+	Captcha    *CaptchaConfig    `mapstructure:"Captcha"`
+	LDAP       *LDAPConfig       `mapstructure:"LDAP"`
+	OIDC       *OIDCConfig       `mapstructure:"OIDC"`
+	Notifier   *NotifierConfig   `mapstructure:"Notifier"`
 	Casbin     *CasbinConfig     `mapstructure:"Casbin"`
 	Redis      *RedisConfig      `mapstructure:"Redis"`
 	Database   *DatabaseConfig   `mapstructure:"Database"`
 }
 
+// TrustedProxies : Networks of the proxies whose X-Forwarded-For is trusted for the client ip,
+//                  the client ip is the remote address of the connection when empty
 type HttpConfig struct {
-	Host string `mapstructure:"Host" validate:"ipv4"`
-	Port int    `mapstructure:"Port" validate:"gte=1,lte=65535"`
+	Host           string   `mapstructure:"Host" validate:"ipv4"`
+	Port           int      `mapstructure:"Port" validate:"gte=1,lte=65535"`
+	TrustedProxies []string `mapstructure:"TrustedProxies"`
 }
 
 // LogLevel     : debug,info,warn,error,dpanic,panic,fatal
@@ -97,24 +119,242 @@ type LogConfig struct {
 	Development bool   `mapstructure:"Development"`
 }
 
+// Enable       : Disabled in production, the username stays reserved
+// Username     : Subject that bypasses casbin, it is also the id of the super admin
+// PasswordHash : argon2id or bcrypt hash of the password, printed by the hashpasswd command
 type SuperAdminConfig struct {
-	Username string `mapstructure:"Username"`
-	Realname string `mapstructure:"Realname"`
-	Password string `mapstructure:"Password"`
+	Enable       bool   `mapstructure:"Enable"`
+	Username     string `mapstructure:"Username"`
+	Realname     string `mapstructure:"Realname"`
+	PasswordHash string `mapstructure:"PasswordHash"`
 }
 
+// TokenExpired        : Lifetime of the access token in seconds
+// RefreshTokenExpired : Lifetime of the refresh token in seconds, 0 disables refresh tokens
+// SigningKeyID        : ID of the key used to sign new tokens, default the first signing key
+// SigningKeys         : Keys accepted to verify tokens, identified by the kid header
+// TwoFactor           : TOTP two-factor authentication
+// LoginGuard          : Throttling and lockout of failed logins
+// ResetTokenExpired   : Lifetime of the password reset token in seconds
+// ResetURL            : Link sent with the reset token, {token} is replaced by the token
+// Impersonation       : Signing in as another user for support
+// Mode                : header, the client sends the token in the Authorization header
+//                       cookie, the tokens are kept in HttpOnly cookies with csrf protection
 type AuthConfig struct {
-	Enable             bool     `mapstructure:"Enable"`
-	TokenExpired       int      `mapstructure:"TokenExpired"`
-	IgnorePathPrefixes []string `mapstructure:"IgnorePathPrefixes"`
+	Enable              bool                 `mapstructure:"Enable"`
+	TokenExpired        int                  `mapstructure:"TokenExpired"`
+	RefreshTokenExpired int                  `mapstructure:"RefreshTokenExpired"`
+	SigningKeyID        string               `mapstructure:"SigningKeyID"`
+	SigningKeys         []*SigningKeyConfig  `mapstructure:"SigningKeys"`
+	TwoFactor           *TwoFactorConfig     `mapstructure:"TwoFactor"`
+	LoginGuard          *LoginGuardConfig    `mapstructure:"LoginGuard"`
+	ResetTokenExpired   int                  `mapstructure:"ResetTokenExpired"`
+	ResetURL            string               `mapstructure:"ResetURL"`
+	Impersonation       *ImpersonationConfig `mapstructure:"Impersonation"`
+	Mode                string               `mapstructure:"Mode"`
+	Cookie              *AuthCookieConfig    `mapstructure:"Cookie"`
+	IgnorePathPrefixes  []string             `mapstructure:"IgnorePathPrefixes"`
+}
+
+// Name        : Cookie of the access token
+// RefreshName : Cookie of the refresh token, only sent to the refresh api
+// CSRFName    : Cookie of the csrf token, it is readable by the frontend
+// CSRFHeader  : Header the frontend copies the csrf token to on mutating requests
+// SameSite    : strict, lax or none, none requires Secure
+type AuthCookieConfig struct {
+	Name        string `mapstructure:"Name"`
+	RefreshName string `mapstructure:"RefreshName"`
+	CSRFName    string `mapstructure:"CSRFName"`
+	CSRFHeader  string `mapstructure:"CSRFHeader"`
+	Domain      string `mapstructure:"Domain"`
+	Secure      bool   `mapstructure:"Secure"`
+	SameSite    string `mapstructure:"SameSite"`
+}
+
+// Expired  : Lifetime of the impersonation token in seconds, it cannot be refreshed
+// ReadOnly : Impersonation sessions only pass the safe http methods
+type ImpersonationConfig struct {
+	Expired  int  `mapstructure:"Expired"`
+	ReadOnly bool `mapstructure:"ReadOnly"`
+}
+
+// Algorithm     : HS256, HS384, HS512, RS256, RS384, RS512, ES256, ES384, ES512
+// Secret        : Shared secret of the HS algorithms
+// KeyFile       : PEM encoded private key of the RS/ES algorithms
+// PublicKeyFile : PEM encoded public key of a retired RS/ES key, only used to verify tokens
+type SigningKeyConfig struct {
+	ID            string `mapstructure:"ID"`
+	Algorithm     string `mapstructure:"Algorithm"`
+	Secret        string `mapstructure:"Secret"`
+	KeyFile       string `mapstructure:"KeyFile"`
+	PublicKeyFile string `mapstructure:"PublicKeyFile"`
+}
+
+// Issuer        : Issuer shown by the authenticator apps, default the app name
+// RequiredRoles : Names of the roles whose users must enable two-factor authentication
+type TwoFactorConfig struct {
+	Issuer        string   `mapstructure:"Issuer"`
+	RequiredRoles []string `mapstructure:"RequiredRoles"`
+}
+
+// MaxAttempts   : Failed logins of a username before the account is locked, 0 disables the lock
+// LockDuration  : Seconds an account or ip stays locked
+// IPMaxAttempts : Failed logins from an ip before the ip is locked, 0 disables the lock
+// Window        : Seconds failed logins are counted for after the last one
+// BackoffAfter  : Failed logins of a username before the next attempts have to wait, 0 disables the backoff
+// BackoffBase   : Seconds of the first wait, doubled by each further failed login
+// BackoffMax    : Maximum seconds of a wait
+type LoginGuardConfig struct {
+	Enable        bool `mapstructure:"Enable"`
+	MaxAttempts   int  `mapstructure:"MaxAttempts"`
+	LockDuration  int  `mapstructure:"LockDuration"`
+	IPMaxAttempts int  `mapstructure:"IPMaxAttempts"`
+	Window        int  `mapstructure:"Window"`
+	BackoffAfter  int  `mapstructure:"BackoffAfter"`
+	BackoffBase   int  `mapstructure:"BackoffBase"`
+	BackoffMax    int  `mapstructure:"BackoffMax"`
+}
+
+// Algorithm     : argon2id, bcrypt, default argon2id
+// BcryptCost    : Cost of bcrypt, default 10
+// Argon2Time    : Iterations of argon2id, default 3
+// Argon2Memory  : Memory of argon2id in KiB, default 65536
+// Argon2Threads : Parallelism of argon2id, default 2
+// MinLength     : Minimum number of characters
+// Require*      : Character classes a password must contain
+// Blocklist     : Common passwords that are refused, case insensitive
+// BlocklistFile : File of more refused passwords, one per line
+// HistorySize   : Number of previous passwords that cannot be reused, 0 disables the check
+// MaxAge        : Days before a password has to be changed, 0 disables the expiry
+type PasswordConfig struct {
+	Algorithm     string `mapstructure:"Algorithm"`
+	BcryptCost    int    `mapstructure:"BcryptCost"`
+	Argon2Time    uint32 `mapstructure:"Argon2Time"`
+	Argon2Memory  uint32 `mapstructure:"Argon2Memory"`
+	Argon2Threads uint8  `mapstructure:"Argon2Threads"`
+
+	MinLength        int      `mapstructure:"MinLength"`
+	RequireUppercase bool     `mapstructure:"RequireUppercase"`
+	RequireLowercase bool     `mapstructure:"RequireLowercase"`
+	RequireDigit     bool     `mapstructure:"RequireDigit"`
+	RequireSymbol    bool     `mapstructure:"RequireSymbol"`
+	Blocklist        []string `mapstructure:"Blocklist"`
+	BlocklistFile    string   `mapstructure:"BlocklistFile"`
+	HistorySize      int      `mapstructure:"HistorySize"`
+	MaxAge           int      `mapstructure:"MaxAge"`
+}
+
+// Driver          : string, digit, math, audio, default string
+// Height, Width   : Size of the image in pixels, default 46x140
+// Length          : Characters or digits to solve, default 4 for string, 5 for digit, 6 for audio
+// NoiseCount      : Noise characters of the string and math images
+// ShowLineOptions : Lines drawn over the string and math images, 2 hollow, 4 slime, 8 sine, added up
+// Source          : Characters of the string captcha
+// Language        : Language of the audio captcha, en, ja, ru, zh, default en
+// RequireAfter    : Failed logins of a username or ip before a login needs a captcha, 0 always needs one
+// TrustedCIDRs    : Networks of internal clients that never need a captcha, see Http.TrustedProxies for the client ip
+type CaptchaConfig struct {
+	Driver          string   `mapstructure:"Driver"`
+	Height          int      `mapstructure:"Height"`
+	Width           int      `mapstructure:"Width"`
+	Length          int      `mapstructure:"Length"`
+	NoiseCount      int      `mapstructure:"NoiseCount"`
+	ShowLineOptions int      `mapstructure:"ShowLineOptions"`
+	Source          string   `mapstructure:"Source"`
+	Language        string   `mapstructure:"Language"`
+	RequireAfter    int      `mapstructure:"RequireAfter"`
+	TrustedCIDRs    []string `mapstructure:"TrustedCIDRs"`
+}
+
+// URL                : ldap://host:389 or ldaps://host:636
+// StartTLS           : Upgrade the ldap:// connection with StartTLS
+// InsecureSkipVerify : Skip the verification of the server certificate
+// Timeout            : Seconds to wait for the server, default 5
+// BindDN             : Account the users are searched with, empty binds anonymously
+// UserFilter         : Filter of the user, %s is replaced by the username, default (uid=%s)
+// *Attribute         : Attributes of the user profile, default uid, cn, mail, telephoneNumber
+// GroupAttribute     : Attribute of the user that lists the dn of its groups, e.g. memberOf
+// GroupFilter        : Filter of the groups of the user, %s is replaced by the user dn, e.g. (member=%s)
+// GroupRoles         : Roles granted by the groups, the mapped roles of a user are synced on every login
+type LDAPConfig struct {
+	Enable             bool   `mapstructure:"Enable"`
+	URL                string `mapstructure:"URL"`
+	StartTLS           bool   `mapstructure:"StartTLS"`
+	InsecureSkipVerify bool   `mapstructure:"InsecureSkipVerify"`
+	Timeout            int    `mapstructure:"Timeout"`
+	BindDN             string `mapstructure:"BindDN"`
+	BindPassword       string `mapstructure:"BindPassword"`
+	BaseDN             string `mapstructure:"BaseDN"`
+	UserFilter         string `mapstructure:"UserFilter"`
+
+	UsernameAttribute string `mapstructure:"UsernameAttribute"`
+	RealnameAttribute string `mapstructure:"RealnameAttribute"`
+	EmailAttribute    string `mapstructure:"EmailAttribute"`
+	PhoneAttribute    string `mapstructure:"PhoneAttribute"`
+
+	GroupAttribute string             `mapstructure:"GroupAttribute"`
+	GroupBaseDN    string             `mapstructure:"GroupBaseDN"`
+	GroupFilter    string             `mapstructure:"GroupFilter"`
+	GroupRoles     []*GroupRoleConfig `mapstructure:"GroupRoles"`
+}
+
+// Issuer        : URL of the provider, the metadata is read from /.well-known/openid-configuration
+// RedirectURL   : Callback registered at the provider, ends with /api/v1/publics/oidc/callback
+// Scopes        : Requested scopes, default openid, profile, email
+// UsernameClaim : Claim of the username, default preferred_username
+// GroupsClaim   : Claim of the groups, default groups
+// GroupRoles    : Roles granted by the groups, the mapped roles of a user are synced on every login
+// LinkByEmail   : Link an existing user with the same verified mail address on the first login
+// SuccessURL    : Frontend page the tokens are passed to in the url fragment, empty responds with json
+type OIDCConfig struct {
+	Enable        bool               `mapstructure:"Enable"`
+	Issuer        string             `mapstructure:"Issuer"`
+	ClientID      string             `mapstructure:"ClientID"`
+	ClientSecret  string             `mapstructure:"ClientSecret"`
+	RedirectURL   string             `mapstructure:"RedirectURL"`
+	Scopes        []string           `mapstructure:"Scopes"`
+	UsernameClaim string             `mapstructure:"UsernameClaim"`
+	GroupsClaim   string             `mapstructure:"GroupsClaim"`
+	GroupRoles    []*GroupRoleConfig `mapstructure:"GroupRoles"`
+	LinkByEmail   bool               `mapstructure:"LinkByEmail"`
+	SuccessURL    string             `mapstructure:"SuccessURL"`
+}
+
+// Group : Name or dn of the group, case insensitive
+// Roles : Names of the roles granted to the members of the group
+type GroupRoleConfig struct {
+	Group string   `mapstructure:"Group"`
+	Roles []string `mapstructure:"Roles"`
+}
+
+// Driver : smtp, log, file, default log
+// File   : File the messages are appended to by the file driver
+type NotifierConfig struct {
+	Driver string      `mapstructure:"Driver"`
+	File   string      `mapstructure:"File"`
+	SMTP   *SMTPConfig `mapstructure:"SMTP"`
+}
+
+// TLS : Connect with implicit TLS, usually on port 465, otherwise STARTTLS is used when offered
+type SMTPConfig struct {
+	Host     string `mapstructure:"Host"`
+	Port     int    `mapstructure:"Port"`
+	Username string `mapstructure:"Username"`
+	Password string `mapstructure:"Password"`
+	From     string `mapstructure:"From"`
+	TLS      bool   `mapstructure:"TLS"`
 }
 
+// AutoLoad         : Reload the whole policy periodically to check the incremental changes
+// AutoLoadInternal : Seconds between the reloads
+// Watcher          : Send the policy changes to the other instances through redis pub/sub
 type CasbinConfig struct {
 	Enable             bool     `mapstructure:"Enable"`
 	Debug              bool     `mapstructure:"Debug"`
 	Model              string   `mapstructure:"Model"`
 	AutoLoad           bool     `mapstructure:"AutoLoad"`
 	AutoLoadInternal   int      `mapstructure:"AutoLoadInternal"`
+	Watcher            bool     `mapstructure:"Watcher"`
 	IgnorePathPrefixes []string `mapstructure:"IgnorePathPrefixes"`
 }
 
@@ -1,6 +1,8 @@
 package lib
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"gorm.io/driver/mysql"
@@ -52,3 +54,83 @@ func NewDatabase(config Config, logger Logger) Database {
 		ORM: db,
 	}
 }
+
+type trxHooksKey struct{}
+
+// TrxHooks are the functions run after a transaction commits
+type TrxHooks struct {
+	mu    sync.Mutex
+	hooks []func()
+}
+
+// WithTrxHooks returns a context that collects the hooks of the transactions begun with it
+func WithTrxHooks(ctx context.Context, hooks *TrxHooks) context.Context {
+	return context.WithValue(ctx, trxHooksKey{}, hooks)
+}
+
+// Run runs the collected hooks once
+func (a *TrxHooks) Run() {
+	a.mu.Lock()
+	hooks := a.hooks
+	a.hooks = nil
+	a.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// AfterCommit runs fn once the transaction commits, nothing runs when it rolls back,
+// fn runs right away when there is no transaction
+func AfterCommit(trxHandle *gorm.DB, fn func()) {
+	if trxHandle != nil && trxHandle.Statement != nil && trxHandle.Statement.Context != nil {
+		if hooks, ok := trxHandle.Statement.Context.Value(trxHooksKey{}).(*TrxHooks); ok {
+			hooks.mu.Lock()
+			hooks.hooks = append(hooks.hooks, fn)
+			hooks.mu.Unlock()
+			return
+		}
+	}
+
+	fn()
+}
+
+type dataScopeKey struct{}
+
+// DataScope limits the rows the current user reaches to its own rows and the rows of the departments
+type DataScope struct {
+	UserID        string
+	DepartmentIDs []string
+}
+
+// dataScopeLoader loads the data scope once, on the first query that applies it
+type dataScopeLoader struct {
+	once  sync.Once
+	load  func() (*DataScope, error)
+	scope *DataScope
+	err   error
+}
+
+// WithDataScope returns a context whose queries are limited to the data scope load returns,
+// a nil scope reaches all rows
+func WithDataScope(ctx context.Context, load func() (*DataScope, error)) context.Context {
+	return context.WithValue(ctx, dataScopeKey{}, &dataScopeLoader{load: load})
+}
+
+// DataScopeOf returns the data scope of the database handle, nil when it is not limited
+func DataScopeOf(db *gorm.DB) (*DataScope, error) {
+	if db == nil || db.Statement == nil || db.Statement.Context == nil {
+		return nil, nil
+	}
+
+	loader, ok := db.Statement.Context.Value(dataScopeKey{}).(*dataScopeLoader)
+	if !ok || loader.load == nil {
+		return nil, nil
+	}
+
+	loader.once.Do(func() {
+		loader.scope, loader.err = loader.load()
+	})
+
+	return loader.scope, loader.err
+}
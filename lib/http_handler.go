@@ -51,6 +51,28 @@ func NewHttpHandler(logger Logger, config Config) HttpHandler {
 	engine.HideBanner = true
 	engine.Binder = &BinderWithValidation{}
 
+	// the client ip is only read from X-Forwarded-For behind the trusted proxies
+	trustedProxies, err := parseTrustedCIDRs(config.Http.TrustedProxies)
+	if err != nil {
+		logger.Zap.Fatalf("Error to set up trusted proxies: %v", err)
+	}
+
+	if len(trustedProxies) == 0 {
+		engine.IPExtractor = echo.ExtractIPDirect()
+	} else {
+		options := []echo.TrustOption{
+			echo.TrustLoopback(false),
+			echo.TrustLinkLocal(false),
+			echo.TrustPrivateNet(false),
+		}
+
+		for _, network := range trustedProxies {
+			options = append(options, echo.TrustIPRange(network))
+		}
+
+		engine.IPExtractor = echo.ExtractIPFromXFFHeader(options...)
+	}
+
 	// set http handler
 	httpHandler := HttpHandler{
 		Engine:   engine,
@@ -10,4 +10,6 @@ var Module = fx.Options(
 	fx.Provide(NewDatabase),
 	fx.Provide(NewRedis),
 	fx.Provide(NewCaptcha),
+	fx.Provide(NewNotifier),
+	fx.Provide(NewAuthCookie),
 )
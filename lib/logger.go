@@ -62,6 +62,12 @@ func NewLogger(config Config) Logger {
 	return Logger{Zap: logger.Sugar(), DesugarZap: logger}
 }
 
+// Security logs an event of the security log, e.g. super admin logins,
+// the events are written by the logger named security with the event field
+func (a Logger) Security(event string, keysAndValues ...interface{}) {
+	a.Zap.Named("security").With("event", event).Warnw(event, keysAndValues...)
+}
+
 func localTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format(constants.TimeFormat))
 }
@@ -0,0 +1,179 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RealLiuSha/echo-admin/errors"
+	"go.uber.org/zap"
+)
+
+// NotifyMessage is a plain text message to a user
+type NotifyMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users
+type Notifier interface {
+	Notify(message *NotifyMessage) error
+}
+
+// NewNotifier creates the notifier of the configured driver
+func NewNotifier(config Config, logger Logger) Notifier {
+	conf := config.Notifier
+	if conf == nil {
+		conf = &NotifierConfig{}
+	}
+
+	switch conf.Driver {
+	case "", "log":
+		return &logNotifier{logger: logger.Zap.With(zap.String("module", "notifier"))}
+	case "file":
+		if conf.File == "" {
+			logger.Zap.Fatal("Error to create notifier: Notifier.File is required by the file driver")
+		}
+
+		return &fileNotifier{path: conf.File}
+	case "smtp":
+		if conf.SMTP == nil || conf.SMTP.Host == "" || conf.SMTP.From == "" {
+			logger.Zap.Fatal("Error to create notifier: Notifier.SMTP.Host and From are required by the smtp driver")
+		}
+
+		return &smtpNotifier{conf: conf.SMTP}
+	default:
+		logger.Zap.Fatalf("Error to create notifier: unsupported driver %s", conf.Driver)
+		return nil
+	}
+}
+
+// logNotifier writes the messages to the log, for development
+type logNotifier struct {
+	logger *zap.SugaredLogger
+}
+
+func (a *logNotifier) Notify(message *NotifyMessage) error {
+	a.logger.Infof("notify %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// fileNotifier appends the messages to a file, for development and tests
+type fileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (a *fileNotifier) Notify(message *NotifyMessage) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z), message.To, message.Subject, message.Body)
+	return err
+}
+
+// smtpNotifier sends the messages as mails
+type smtpNotifier struct {
+	conf *SMTPConfig
+}
+
+func (a *smtpNotifier) Notify(message *NotifyMessage) error {
+	if message.To == "" {
+		return errors.New("notifier: the recipient has no mail address")
+	}
+
+	from, err := mail.ParseAddress(a.conf.From)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(a.conf.Host, strconv.Itoa(a.conf.Port))
+
+	var auth smtp.Auth
+	if a.conf.Username != "" {
+		auth = smtp.PlainAuth("", a.conf.Username, a.conf.Password, a.conf.Host)
+	}
+
+	msg := a.encode(message)
+	if !a.conf.TLS {
+		// upgraded with STARTTLS when the server supports it
+		return smtp.SendMail(addr, auth, from.Address, []string{message.To}, msg)
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: a.conf.Host})
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, a.conf.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+
+	if err := client.Rcpt(message.To); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (a *smtpNotifier) encode(message *NotifyMessage) []byte {
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "From: %s\r\n", a.conf.From)
+	fmt.Fprintf(buf, "To: %s\r\n", message.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(message.Body)
+
+	return buf.Bytes()
+}
@@ -68,6 +68,17 @@ func (a Redis) Get(key string, value interface{}) error {
 	return err
 }
 
+// GetSkippingLocalCache always reads the value from redis,
+// use it for state that must not be served stale from the local cache
+func (a Redis) GetSkippingLocalCache(key string, value interface{}) error {
+	err := a.cache.GetSkippingLocalCache(context.TODO(), a.wrapperKey(key), value)
+	if err == cache.ErrCacheMiss {
+		err = errors.RedisKeyNoExist
+	}
+
+	return err
+}
+
 func (a Redis) Delete(keys ...string) (bool, error) {
 	wrapperKeys := make([]string, len(keys))
 	for index, key := range keys {
@@ -95,6 +106,71 @@ func (a Redis) Check(keys ...string) (bool, error) {
 	return cmd.Val() > 0, nil
 }
 
+func (a Redis) TTL(key string) (time.Duration, error) {
+	cmd := a.client.TTL(context.TODO(), a.wrapperKey(key))
+	if err := cmd.Err(); err != nil {
+		return 0, err
+	}
+
+	return cmd.Val(), nil
+}
+
+func (a Redis) Expire(key string, expiration time.Duration) error {
+	return a.client.Expire(context.TODO(), a.wrapperKey(key), expiration).Err()
+}
+
+// Incr increments the counter and restarts its expiration
+func (a Redis) Incr(key string, expiration time.Duration) (int64, error) {
+	key = a.wrapperKey(key)
+
+	pipe := a.client.TxPipeline()
+	incr := pipe.Incr(context.TODO(), key)
+	pipe.Expire(context.TODO(), key, expiration)
+
+	if _, err := pipe.Exec(context.TODO()); err != nil {
+		return 0, err
+	}
+
+	return incr.Val(), nil
+}
+
+// Count returns the value of a counter of Incr, 0 when it does not exist
+func (a Redis) Count(key string) (int64, error) {
+	n, err := a.client.Get(context.TODO(), a.wrapperKey(key)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+
+	return n, err
+}
+
+func (a Redis) SetAdd(key string, members ...interface{}) error {
+	return a.client.SAdd(context.TODO(), a.wrapperKey(key), members...).Err()
+}
+
+func (a Redis) SetRemove(key string, members ...interface{}) error {
+	return a.client.SRem(context.TODO(), a.wrapperKey(key), members...).Err()
+}
+
+func (a Redis) SetMembers(key string) ([]string, error) {
+	cmd := a.client.SMembers(context.TODO(), a.wrapperKey(key))
+	if err := cmd.Err(); err != nil {
+		return nil, err
+	}
+
+	return cmd.Val(), nil
+}
+
+// Publish sends the message to the subscribers of the channel
+func (a Redis) Publish(channel string, message interface{}) error {
+	return a.client.Publish(context.TODO(), a.wrapperKey(channel), message).Err()
+}
+
+// Subscribe listens to the channel until the subscription is closed
+func (a Redis) Subscribe(channel string) *redis.PubSub {
+	return a.client.Subscribe(context.TODO(), a.wrapperKey(channel))
+}
+
 func (a Redis) Close() error {
 	return a.client.Close()
 }
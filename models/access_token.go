@@ -0,0 +1,46 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+)
+
+// AccessToken long-lived token of machine clients, it acts with the roles of its user,
+// only the sha256 of the token is stored
+type AccessToken struct {
+	database.Model
+	ID         string            `gorm:"column:id;size:36;not null;index;" json:"id"`
+	UserID     string            `gorm:"column:user_id;size:36;not null;index;" json:"user_id"`
+	Name       string            `gorm:"column:name;size:64;not null;" json:"name"`
+	Token      string            `gorm:"column:token;size:64;not null;index;" json:"-"`
+	Prefix     string            `gorm:"column:prefix;size:16;not null;" json:"prefix"`
+	Scopes     string            `gorm:"column:scopes;size:128;not null;" json:"scopes"`
+	ExpiresAt  database.Datetime `gorm:"column:expires_at;" json:"expires_at"`
+	LastUsedAt database.Datetime `gorm:"column:last_used_at;" json:"last_used_at"`
+	CreatedBy  string            `gorm:"column:created_by;not null;" json:"created_by"`
+}
+
+type AccessTokens []*AccessToken
+
+type AccessTokenQueryParam struct {
+	dto.PaginationParam
+	dto.OrderParam
+
+	UserID string
+	Token  string
+}
+
+type AccessTokenQueryResult struct {
+	List       AccessTokens    `json:"list"`
+	Pagination *dto.Pagination `json:"pagination"`
+}
+
+func (a *AccessToken) SplitScopes() []string {
+	if a.Scopes == "" {
+		return nil
+	}
+
+	return strings.Split(a.Scopes, ",")
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+)
+
+// Status - 1: Enable -1: Disable
+type Department struct {
+	database.Model
+	ID         string `gorm:"column:id;size:36;not null;index;" json:"id"`
+	Name       string `gorm:"column:name;not null;index;" json:"name" validate:"required"`
+	Sequence   int    `gorm:"column:sequence;not null;index;" json:"sequence" validate:"required"`
+	ParentID   string `gorm:"column:parent_id;size:36;index;" json:"parent_id"`
+	ParentPath string `gorm:"column:parent_path;" json:"parent_path"`
+	Status     int    `gorm:"column:status;not null;" json:"status" validate:"required,max=1,min=-1"`
+	Remark     string `gorm:"column:remark;" json:"remark"`
+	CreatedBy  string `gorm:"column:created_by;not null;" json:"created_by"`
+	// users leading the department
+	Leaders DepartmentLeaders `gorm:"-" json:"leaders"`
+}
+
+type DepartmentTree struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	ParentID   string          `json:"parent_id"`
+	ParentPath string          `json:"parent_path"`
+	Sequence   int             `json:"sequence"`
+	Status     int             `json:"status"`
+	Children   DepartmentTrees `json:"children,omitempty"`
+}
+
+type Departments []*Department
+type DepartmentTrees []*DepartmentTree
+
+type DepartmentQueryParam struct {
+	dto.PaginationParam
+	dto.OrderParam
+
+	IDs              []string `query:"ids"`
+	Name             string   `query:"name"`
+	PrefixParentPath string   `query:"prefix_parent_path"`
+	QueryValue       string   `query:"query_value"`
+	ParentID         string   `query:"parent_id"`
+	Status           int      `query:"status" validate:"max=1,min=-1"`
+}
+
+type DepartmentQueryResult struct {
+	List       Departments     `json:"list"`
+	Pagination *dto.Pagination `json:"pagination"`
+}
+
+func (a Departments) ToMap() map[string]*Department {
+	m := make(map[string]*Department)
+	for _, item := range a {
+		m[item.ID] = item
+	}
+
+	return m
+}
+
+func (a Departments) ToIDs() []string {
+	ids := make([]string, len(a))
+	for i, item := range a {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+func (a Departments) ToDepartmentTrees() DepartmentTrees {
+	trees := make(DepartmentTrees, len(a))
+	for i, item := range a {
+		trees[i] = &DepartmentTree{
+			ID:         item.ID,
+			Name:       item.Name,
+			ParentID:   item.ParentID,
+			ParentPath: item.ParentPath,
+			Sequence:   item.Sequence,
+			Status:     item.Status,
+		}
+	}
+
+	return trees.ToTree()
+}
+
+func (a DepartmentTrees) ToTree() DepartmentTrees {
+	treeMap := make(map[string]*DepartmentTree)
+	for _, item := range a {
+		treeMap[item.ID] = item
+	}
+
+	trees := make(DepartmentTrees, 0)
+	for _, item := range a {
+		if item.ParentID == "" {
+			trees = append(trees, item)
+			continue
+		}
+
+		if parent, ok := treeMap[item.ParentID]; ok {
+			parent.Children = append(parent.Children, item)
+		}
+	}
+
+	return trees
+}
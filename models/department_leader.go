@@ -0,0 +1,47 @@
+package models
+
+import (
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+)
+
+type DepartmentLeader struct {
+	database.Model
+	ID           string `gorm:"column:id;size:36;not null;" json:"id"`
+	DepartmentID string `gorm:"column:department_id;size:36;index;not null;" json:"department_id"`
+	UserID       string `gorm:"column:user_id;size:36;index;not null;" json:"user_id" validate:"required"`
+}
+
+type DepartmentLeaders []*DepartmentLeader
+
+type DepartmentLeaderQueryParam struct {
+	dto.PaginationParam
+	dto.OrderParam
+
+	DepartmentID  string
+	DepartmentIDs []string
+	UserID        string
+}
+
+type DepartmentLeaderQueryResult struct {
+	List       DepartmentLeaders `json:"list"`
+	Pagination *dto.Pagination   `json:"pagination"`
+}
+
+func (a DepartmentLeaders) ToMap() map[string]*DepartmentLeader {
+	m := make(map[string]*DepartmentLeader)
+	for _, item := range a {
+		m[item.UserID] = item
+	}
+
+	return m
+}
+
+func (a DepartmentLeaders) ToDepartmentIDMap() map[string]DepartmentLeaders {
+	m := make(map[string]DepartmentLeaders)
+	for _, item := range a {
+		m[item.DepartmentID] = append(m[item.DepartmentID], item)
+	}
+
+	return m
+}
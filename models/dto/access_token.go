@@ -0,0 +1,14 @@
+package dto
+
+type CreateAccessToken struct {
+	Name   string   `json:"name" validate:"required,max=64"`
+	Scopes []string `json:"scopes" validate:"required,dive,oneof=read write"`
+	// days until the token expires, zero never expires
+	ExpiresIn int `json:"expires_in" validate:"min=0"`
+}
+
+// AccessTokenSecret is returned once when the token is created, only its hash is kept
+type AccessTokenSecret struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+}
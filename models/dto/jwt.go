@@ -5,7 +5,28 @@ import (
 )
 
 type JwtClaims struct {
-	ID       string
-	Username string
+	ID        string
+	Username  string
+	SessionID string `json:"sid"`
+	// set when an admin acts as the user, the id and username of the admin
+	ImpersonatorID   string `json:"imp,omitempty"`
+	ImpersonatorName string `json:"imp_name,omitempty"`
+	// restrictions are kept in the session, so that they can be lifted without a new token
+	Restrictions []string `json:"-"`
+	// set when the request is authenticated with an access token instead of a jwt
+	AccessTokenID string   `json:"-"`
+	Scopes        []string `json:"-"`
 	jwt.StandardClaims
 }
+
+type TokenPair struct {
+	TokenType    string   `json:"token_type"`
+	AccessToken  string   `json:"token,omitempty"`
+	ExpiresIn    int      `json:"expires_in"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	Restrictions []string `json:"restrictions,omitempty"`
+	// the password has expired, the session only reaches the change password api
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+	// username of the admin acting as the user
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+}
@@ -1,8 +1,36 @@
 package dto
 
+// Login needs the captcha when Captcha.RequireAfter failed logins are reached
 type Login struct {
 	Username    string `json:"username" validate:"required"`
 	Password    string `json:"password" validate:"required"`
-	CaptchaID   string `json:"captcha_id" validate:"required"`
-	CaptchaCode string `json:"captcha_code" validate:"required"`
+	CaptchaID   string `json:"captcha_id"`
+	CaptchaCode string `json:"captcha_code"`
+}
+
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type ChangePassword struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// ForgotPassword needs the captcha unless the client is trusted
+type ForgotPassword struct {
+	Username    string `json:"username" validate:"required"`
+	CaptchaID   string `json:"captcha_id"`
+	CaptchaCode string `json:"captcha_code"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// OIDCState is the pending authorization request of an oidc login
+type OIDCState struct {
+	Nonce    string
+	Verifier string
 }
@@ -0,0 +1,42 @@
+package dto
+
+// Session is the server side state of a login,
+// the tokens issued for it are only accepted while it exists
+type Session struct {
+	ID         string `json:"id"`
+	UserID     string `json:"user_id"`
+	Username   string `json:"username"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	TokenID    string `json:"-"`
+	RefreshID  string `json:"-"`
+	IssuedAt   int64  `json:"issued_at"`
+	LastSeenAt int64  `json:"last_seen_at"`
+	ExpiresAt  int64  `json:"expires_at"`
+	Current    bool   `json:"current" msgpack:"-"`
+	// steps the user has to complete before the session reaches the whole api
+	Restrictions []string `json:"restrictions,omitempty"`
+	// the admin acting as the user
+	ImpersonatorID   string `json:"impersonator_id,omitempty"`
+	ImpersonatorName string `json:"impersonator_name,omitempty"`
+}
+
+type Sessions []*Session
+
+// Client describes where a login comes from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+func (a Sessions) Len() int {
+	return len(a)
+}
+
+func (a Sessions) Less(i, j int) bool {
+	return a[i].IssuedAt > a[j].IssuedAt
+}
+
+func (a Sessions) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
@@ -0,0 +1,31 @@
+package dto
+
+// LoginChallenge is returned by the login instead of a token when a second factor is needed
+type LoginChallenge struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// TwoFactorChallenge is the pending second step of a login
+type TwoFactorChallenge struct {
+	UserID string
+}
+
+type LoginTwoFactor struct {
+	Challenge string `json:"challenge" validate:"required"`
+	Code      string `json:"code" validate:"required"`
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+)
+
+// LoginLog an attempt to sign in, successful or not, the time of the attempt is created_at
+type LoginLog struct {
+	database.Model
+	ID        string `gorm:"column:id;size:36;not null;index;" json:"id"`
+	UserID    string `gorm:"column:user_id;size:36;not null;default:'';index;" json:"user_id"`
+	Username  string `gorm:"column:username;size:64;not null;default:'';index;" json:"username"`
+	Method    string `gorm:"column:method;size:16;not null;" json:"method"`
+	IP        string `gorm:"column:ip;size:64;not null;default:'';index;" json:"ip"`
+	UserAgent string `gorm:"column:user_agent;size:255;not null;default:'';" json:"user_agent"`
+	Success   bool   `gorm:"column:success;not null;default:false;" json:"success"`
+	Reason    string `gorm:"column:reason;size:255;not null;default:'';" json:"reason"`
+}
+
+type LoginLogs []*LoginLog
+
+// Status - 1: success -1: failure 0: all
+type LoginLogQueryParam struct {
+	dto.PaginationParam
+	dto.OrderParam
+
+	UserID    string `query:"user_id"`
+	Username  string `query:"username"`
+	IP        string `query:"ip"`
+	Method    string `query:"method"`
+	Status    int    `query:"status" validate:"max=1,min=-1"`
+	StartTime string `query:"start_time" validate:"omitempty,datetime=2006-01-02 15:04:05"`
+	EndTime   string `query:"end_time" validate:"omitempty,datetime=2006-01-02 15:04:05"`
+}
+
+type LoginLogQueryResult struct {
+	List       LoginLogs       `json:"list"`
+	Pagination *dto.Pagination `json:"pagination"`
+}
@@ -39,6 +39,8 @@ type MenuTree struct {
 	Status     int         `yaml:"-" json:"status"`
 	Actions    MenuActions `yaml:"actions,omitempty" json:"actions"`
 	Children   MenuTrees   `yaml:"children,omitempty" json:"children,omitempty"`
+	// codes of the actions the current user is granted on the menu
+	Permissions []string `yaml:"-" json:"permissions,omitempty"`
 }
 
 type Menus []*Menu
@@ -174,3 +176,12 @@ func (a Menus) FillMenuAction(mActions map[string]MenuActions, mResources map[st
 	}
 	return a
 }
+
+func (a MenuTrees) FillPermissions(permissions UserPermissions) MenuTrees {
+	for _, item := range a {
+		item.Permissions = permissions[item.ID]
+		item.Children.FillPermissions(permissions)
+	}
+
+	return a
+}
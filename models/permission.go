@@ -0,0 +1,47 @@
+package models
+
+// PermissionCheckParam asks whether a user may request the path with the method,
+// the current user is checked when neither the user id nor the username is given
+type PermissionCheckParam struct {
+	UserID   string `query:"user_id" json:"user_id"`
+	Username string `query:"username" json:"username"`
+	Method   string `query:"method" json:"method" validate:"required"`
+	Path     string `query:"path" json:"path" validate:"required"`
+}
+
+type PermissionCheckParams []*PermissionCheckParam
+
+// PermissionGrant is a menu action of the granting role whose resource matched the request
+type PermissionGrant struct {
+	MenuID     string `json:"menu_id"`
+	MenuName   string `json:"menu_name"`
+	ActionID   string `json:"action_id"`
+	ActionCode string `json:"action_code"`
+	ActionName string `json:"action_name"`
+}
+
+type PermissionGrants []*PermissionGrant
+
+type PermissionCheckResult struct {
+	UserID  string `json:"user_id"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Allowed bool   `json:"allowed"`
+	// the super admin is allowed without a policy
+	SuperAdmin bool `json:"super_admin,omitempty"`
+	// the roles assigned to the user and the roles they inherit
+	Roles          []string `json:"roles"`
+	InheritedRoles []string `json:"inherited_roles"`
+	// the matched policy (role_id, path, method) and the role and menu actions it comes from,
+	// the role is inherited when it is not assigned to the user
+	Policy        []string         `json:"policy,omitempty"`
+	RoleID        string           `json:"role_id,omitempty"`
+	RoleName      string           `json:"role_name,omitempty"`
+	RoleInherited bool             `json:"role_inherited,omitempty"`
+	Grants        PermissionGrants `json:"grants,omitempty"`
+}
+
+type PermissionCheckResults []*PermissionCheckResult
+
+// UserPermissions are the codes of the actions a user is granted, by menu id
+type UserPermissions map[string][]string
@@ -15,6 +15,11 @@ type Role struct {
 	Status    int       `gorm:"column:status;default:0;not null;" json:"status" validate:"required,max=1,min=-1"`
 	CreatedBy string    `gorm:"column:created_by;not null;" json:"created_by"`
 	RoleMenus RoleMenus `gorm:"-" json:"role_menus"`
+	// roles whose permissions and menus the role includes
+	RoleInherits RoleInherits `gorm:"-" json:"role_inherits"`
+	// the users whose rows the role reaches, the departments are those of the custom scope
+	DataScope       string          `gorm:"column:data_scope;size:32;not null;default:'all';" json:"data_scope" validate:"in=all;department;department_and_children;self;custom"`
+	RoleDepartments RoleDepartments `gorm:"-" json:"role_departments"`
 }
 
 type Roles []*Role
@@ -25,6 +30,7 @@ type RoleQueryParam struct {
 
 	IDs        []string `query:"ids"`
 	Name       string   `query:"name"`
+	Names      []string `query:"-"`
 	QueryValue string   `query:"query_value"`
 	UserID     string   `query:"user_id"`
 	Status     int      `query:"status" validate:"max=1,min=-1"`
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+)
+
+// RoleDepartment is a department of a role with the custom data scope
+type RoleDepartment struct {
+	database.Model
+	ID           string `gorm:"column:id;size:36;not null;" json:"id"`
+	RoleID       string `gorm:"column:role_id;size:36;not null;index;" json:"role_id"`
+	DepartmentID string `gorm:"column:department_id;size:36;not null;index;" json:"department_id" validate:"required"`
+}
+
+type RoleDepartments []*RoleDepartment
+
+type RoleDepartmentQueryParam struct {
+	dto.PaginationParam
+	dto.OrderParam
+
+	RoleID  string
+	RoleIDs []string
+}
+
+type RoleDepartmentQueryResult struct {
+	List       RoleDepartments `json:"list"`
+	Pagination *dto.Pagination `json:"pagination"`
+}
+
+func (a RoleDepartments) ToMap() map[string]*RoleDepartment {
+	m := make(map[string]*RoleDepartment)
+	for _, item := range a {
+		m[item.DepartmentID] = item
+	}
+
+	return m
+}
+
+func (a RoleDepartments) ToRoleIDMap() map[string]RoleDepartments {
+	m := make(map[string]RoleDepartments)
+	for _, item := range a {
+		m[item.RoleID] = append(m[item.RoleID], item)
+	}
+
+	return m
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+)
+
+// RoleInherit grants the role the permissions and menus of the inherited role
+type RoleInherit struct {
+	database.Model
+	ID        string `gorm:"column:id;size:36;not null;" json:"id"`
+	RoleID    string `gorm:"column:role_id;size:36;not null;index;" json:"role_id"`
+	InheritID string `gorm:"column:inherit_id;size:36;not null;index;" json:"inherit_id" validate:"required"`
+}
+
+type RoleInherits []*RoleInherit
+
+type RoleInheritQueryParam struct {
+	dto.PaginationParam
+	dto.OrderParam
+
+	RoleID    string
+	RoleIDs   []string
+	InheritID string
+}
+
+type RoleInheritQueryResult struct {
+	List       RoleInherits    `json:"list"`
+	Pagination *dto.Pagination `json:"pagination"`
+}
+
+func (a RoleInherits) ToMap() map[string]*RoleInherit {
+	m := make(map[string]*RoleInherit)
+	for _, item := range a {
+		m[item.InheritID] = item
+	}
+
+	return m
+}
+
+func (a RoleInherits) ToRoleIDMap() map[string]RoleInherits {
+	m := make(map[string]RoleInherits)
+	for _, item := range a {
+		m[item.RoleID] = append(m[item.RoleID], item)
+	}
+
+	return m
+}
+
+func (a RoleInherits) ToInheritIDs() []string {
+	var idList []string
+	m := make(map[string]struct{})
+
+	for _, item := range a {
+		if _, ok := m[item.InheritID]; ok {
+			continue
+		}
+		idList = append(idList, item.InheritID)
+		m[item.InheritID] = struct{}{}
+	}
+
+	return idList
+}
@@ -21,6 +21,7 @@ type RoleMenuQueryParam struct {
 
 	RoleID  string
 	RoleIDs []string
+	MenuID  string
 }
 
 type RoleMenuQueryResult struct {
@@ -46,6 +47,21 @@ func (a RoleMenus) ToRoleIDMap() map[string]RoleMenus {
 	return m
 }
 
+func (a RoleMenus) ToRoleIDs() []string {
+	var idList []string
+	m := make(map[string]struct{})
+
+	for _, item := range a {
+		if _, ok := m[item.RoleID]; ok {
+			continue
+		}
+		idList = append(idList, item.RoleID)
+		m[item.RoleID] = struct{}{}
+	}
+
+	return idList
+}
+
 func (a RoleMenus) ToMenuIDs() []string {
 	var idList []string
 	m := make(map[string]struct{})
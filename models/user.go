@@ -1,6 +1,7 @@
 package models
 
 import (
+	"github.com/RealLiuSha/echo-admin/constants"
 	"github.com/RealLiuSha/echo-admin/models/database"
 	"github.com/RealLiuSha/echo-admin/models/dto"
 )
@@ -17,6 +18,21 @@ type User struct {
 	Status    int       `gorm:"column:status;not null;default:0;" json:"status" validate:"required,max=1,min=-1"`
 	CreatedBy string    `gorm:"column:created_by;not null;" json:"created_by"`
 	UserRoles UserRoles `gorm:"-" json:"user_roles"`
+	// one primary department and any number of secondary departments
+	UserDepartments UserDepartments `gorm:"-" json:"user_departments"`
+
+	PasswordChangedAt database.Datetime `gorm:"column:password_changed_at;" json:"password_changed_at"`
+
+	TwoFactorSecret  string `gorm:"column:two_factor_secret;size:64;not null;default:'';" json:"-"`
+	TwoFactorEnabled bool   `gorm:"column:two_factor_enabled;not null;default:false;" json:"two_factor_enabled"`
+
+	// service accounts have no password and only authenticate with access tokens
+	ServiceAccount bool `gorm:"column:service_account;not null;default:false;" json:"service_account"`
+	// where the password is verified, users of an external source are provisioned on login
+	Source string `gorm:"column:source;size:16;not null;default:'local';" json:"source"`
+
+	LastLoginAt database.Datetime `gorm:"column:last_login_at;" json:"last_login_at"`
+	LastLoginIP string            `gorm:"column:last_login_ip;size:64;not null;default:'';" json:"last_login_ip"`
 }
 
 type Users []*User
@@ -25,7 +41,18 @@ type UserInfo struct {
 	ID       string `json:"user_id"`
 	Username string `json:"username"`
 	Realname string `json:"realname"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
 	Roles    Roles  `json:"roles"`
+	// username of the admin acting as the user
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+}
+
+// UserProfile fields a user can edit on its own
+type UserProfile struct {
+	Realname string `json:"realname" validate:"required"`
+	Email    string `json:"email" validate:"omitempty,email"`
+	Phone    string `json:"phone"`
 }
 
 type UserQueryParam struct {
@@ -34,10 +61,14 @@ type UserQueryParam struct {
 
 	QueryPassword bool
 	Username      string   `query:"username"`
+	Email         string   `query:"email"`
 	Realname      string   `query:"realname"`
 	QueryValue    string   `query:"query_value"`
 	Status        int      `query:"status" validate:"max=1,min=-1"`
 	RoleIDs       []string `query:"-"`
+	// users of the department and of its sub departments
+	DepartmentID  string   `query:"department_id"`
+	DepartmentIDs []string `query:"-"`
 }
 
 type UserQueryResult struct {
@@ -47,9 +78,15 @@ type UserQueryResult struct {
 
 func (a *User) CleanSecure() *User {
 	a.Password = ""
+	a.TwoFactorSecret = ""
 	return a
 }
 
+// IsLocal tells whether the password of the user is kept in the database
+func (a *User) IsLocal() bool {
+	return a.Source == "" || a.Source == constants.UserSourceLocal
+}
+
 func (a Users) ToIDs() []string {
 	ids := make([]string, len(a))
 	for i, item := range a {
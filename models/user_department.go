@@ -0,0 +1,58 @@
+package models
+
+import (
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+)
+
+// Primary - the department the user belongs to, the others are secondary departments
+type UserDepartment struct {
+	database.Model
+	ID           string `gorm:"column:id;size:36;not null;" json:"id"`
+	UserID       string `gorm:"column:user_id;size:36;index;not null;" json:"user_id"`
+	DepartmentID string `gorm:"column:department_id;size:36;index;not null;" json:"department_id" validate:"required"`
+	Primary      bool   `gorm:"column:is_primary;not null;default:false;" json:"primary"`
+}
+
+type UserDepartments []*UserDepartment
+
+type UserDepartmentQueryParam struct {
+	dto.PaginationParam
+	dto.OrderParam
+
+	UserID        string
+	UserIDs       []string
+	DepartmentIDs []string
+}
+
+type UserDepartmentQueryResult struct {
+	List       UserDepartments `json:"list"`
+	Pagination *dto.Pagination `json:"pagination"`
+}
+
+func (a UserDepartments) ToMap() map[string]*UserDepartment {
+	m := make(map[string]*UserDepartment)
+	for _, item := range a {
+		m[item.DepartmentID] = item
+	}
+
+	return m
+}
+
+func (a UserDepartments) ToDepartmentIDs() []string {
+	list := make([]string, len(a))
+	for i, item := range a {
+		list[i] = item.DepartmentID
+	}
+
+	return list
+}
+
+func (a UserDepartments) ToUserIDMap() map[string]UserDepartments {
+	m := make(map[string]UserDepartments)
+	for _, item := range a {
+		m[item.UserID] = append(m[item.UserID], item)
+	}
+
+	return m
+}
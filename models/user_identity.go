@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/RealLiuSha/echo-admin/models/database"
+)
+
+// UserIdentity links a user to the subject of an external identity provider
+type UserIdentity struct {
+	database.Model
+	ID       string `gorm:"column:id;size:36;not null;index;" json:"id"`
+	UserID   string `gorm:"column:user_id;size:36;not null;index;" json:"user_id"`
+	Provider string `gorm:"column:provider;size:255;not null;index:idx_provider_subject;" json:"provider"`
+	Subject  string `gorm:"column:subject;size:255;not null;index:idx_provider_subject;" json:"subject"`
+}
+
+type UserIdentities []*UserIdentity
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+)
+
+// UserPasswordHistory hashes of the previous passwords of a user, refused when the password changes
+type UserPasswordHistory struct {
+	database.Model
+	ID       string `gorm:"column:id;size:36;not null;index;" json:"id"`
+	UserID   string `gorm:"column:user_id;size:36;index;not null;" json:"user_id"`
+	Password string `gorm:"column:password;not null;" json:"-"`
+}
+
+type UserPasswordHistories []*UserPasswordHistory
+
+type UserPasswordHistoryQueryParam struct {
+	dto.PaginationParam
+	dto.OrderParam
+
+	UserID string
+}
+
+type UserPasswordHistoryQueryResult struct {
+	List       UserPasswordHistories `json:"list"`
+	Pagination *dto.Pagination       `json:"pagination"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"github.com/RealLiuSha/echo-admin/models/database"
+	"github.com/RealLiuSha/echo-admin/models/dto"
+)
+
+// UserRecoveryCode one-time code to sign in when the two-factor device is lost,
+// only the sha256 of the code is stored
+type UserRecoveryCode struct {
+	database.Model
+	ID     string `gorm:"column:id;size:36;not null;index;" json:"id"`
+	UserID string `gorm:"column:user_id;size:36;index;not null;" json:"user_id"`
+	Code   string `gorm:"column:code;size:64;index;not null;" json:"-"`
+}
+
+type UserRecoveryCodes []*UserRecoveryCode
+
+type UserRecoveryCodeQueryParam struct {
+	dto.PaginationParam
+	dto.OrderParam
+
+	UserID string
+	Code   string
+}
+
+type UserRecoveryCodeQueryResult struct {
+	List       UserRecoveryCodes `json:"list"`
+	Pagination *dto.Pagination   `json:"pagination"`
+}
@@ -0,0 +1,214 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUnknownHash = errors.New("hash: unknown password hash format")
+	ErrInvalidHash = errors.New("hash: invalid password hash")
+)
+
+// PasswordHasher 密码哈希算法，哈希值自带算法及参数
+type PasswordHasher interface {
+	// Hash returns the encoded hash of the password
+	Hash(password string) (string, error)
+	// Verify compares the password with an encoded hash of this algorithm
+	Verify(password, encoded string) (bool, error)
+	// Identify tells whether the encoded hash belongs to this algorithm
+	Identify(encoded string) bool
+	// NeedsRehash tells whether the encoded hash was made with other parameters
+	NeedsRehash(encoded string) bool
+}
+
+// Passwords 使用首选算法生成密码哈希，并校验所有已知算法的哈希
+type Passwords struct {
+	hasher  PasswordHasher
+	hashers []PasswordHasher
+}
+
+// NewPasswords creates passwords hashing with hasher,
+// the legacy hashers are only used to verify hashes made before
+func NewPasswords(hasher PasswordHasher, legacy ...PasswordHasher) *Passwords {
+	return &Passwords{
+		hasher:  hasher,
+		hashers: append([]PasswordHasher{hasher}, legacy...),
+	}
+}
+
+func (a *Passwords) Hash(password string) (string, error) {
+	return a.hasher.Hash(password)
+}
+
+// Verify compares the password with the encoded hash,
+// rehash reports that the hash should be replaced by a hash of the preferred hasher
+func (a *Passwords) Verify(password, encoded string) (ok bool, rehash bool, err error) {
+	for _, hasher := range a.hashers {
+		if !hasher.Identify(encoded) {
+			continue
+		}
+
+		if ok, err = hasher.Verify(password, encoded); err != nil || !ok {
+			return false, false, err
+		}
+
+		return true, hasher != a.hasher || hasher.NeedsRehash(encoded), nil
+	}
+
+	return false, false, ErrUnknownHash
+}
+
+// Argon2id 哈希，编码为 $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type Argon2id struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// NewArgon2id returns an argon2id hasher with the recommended parameters
+func NewArgon2id() *Argon2id {
+	return &Argon2id{Time: 3, Memory: 64 * 1024, Threads: 2, SaltLen: 16, KeyLen: 32}
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Time, a.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2id) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := a.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (a *Argon2id) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (a *Argon2id) NeedsRehash(encoded string) bool {
+	params, salt, key, err := a.decode(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.Time != a.Time || params.Memory != a.Memory || params.Threads != a.Threads ||
+		uint32(len(salt)) != a.SaltLen || uint32(len(key)) != a.KeyLen
+}
+
+func (a *Argon2id) decode(encoded string) (params *Argon2id, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	params = new(Argon2id)
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	return params, salt, key, nil
+}
+
+// Bcrypt 哈希，编码为 $2a$<cost>$<salt+key>
+type Bcrypt struct {
+	Cost int
+}
+
+// NewBcrypt returns a bcrypt hasher with the given cost, the default cost when it is 0
+func NewBcrypt(cost int) *Bcrypt {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+
+	return &Bcrypt{Cost: cost}
+}
+
+func (a *Bcrypt) Hash(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), a.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func (a *Bcrypt) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	} else if err != nil {
+		return false, ErrInvalidHash
+	}
+
+	return true, nil
+}
+
+func (a *Bcrypt) Identify(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (a *Bcrypt) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != a.Cost
+}
+
+// LegacySHA256 无盐 SHA256 哈希，仅用于校验旧密码
+type LegacySHA256 struct{}
+
+func (a LegacySHA256) Hash(password string) (string, error) {
+	return SHA256(password), nil
+}
+
+func (a LegacySHA256) Verify(password, encoded string) (bool, error) {
+	return subtle.ConstantTimeCompare([]byte(SHA256(password)), []byte(strings.ToLower(encoded))) == 1, nil
+}
+
+func (a LegacySHA256) Identify(encoded string) bool {
+	if len(encoded) != 64 {
+		return false
+	}
+
+	_, err := hex.DecodeString(encoded)
+	return err == nil
+}
+
+func (a LegacySHA256) NeedsRehash(encoded string) bool {
+	return true
+}
@@ -0,0 +1,92 @@
+package hash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgon2id(t *testing.T) {
+	hasher := &Argon2id{Time: 1, Memory: 1024, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+	encoded, err := hasher.Hash("secret")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.True(t, hasher.Identify(encoded))
+	assert.False(t, hasher.NeedsRehash(encoded))
+
+	other, _ := hasher.Hash("secret")
+	assert.NotEqual(t, encoded, other)
+
+	ok, err := hasher.Verify("secret", encoded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = hasher.Verify("wrong", encoded)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = hasher.Verify("secret", "$argon2id$v=19$m=1024$bad")
+	assert.Equal(t, ErrInvalidHash, err)
+
+	stronger := &Argon2id{Time: 2, Memory: 1024, Threads: 1, SaltLen: 16, KeyLen: 32}
+	assert.True(t, stronger.NeedsRehash(encoded))
+
+	// hashes made with other parameters are still verified
+	ok, err = stronger.Verify("secret", encoded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestBcrypt(t *testing.T) {
+	hasher := NewBcrypt(4)
+
+	encoded, err := hasher.Hash("secret")
+	assert.NoError(t, err)
+	assert.True(t, hasher.Identify(encoded))
+	assert.False(t, hasher.NeedsRehash(encoded))
+	assert.True(t, NewBcrypt(5).NeedsRehash(encoded))
+
+	ok, err := hasher.Verify("secret", encoded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = hasher.Verify("wrong", encoded)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestPasswords(t *testing.T) {
+	bcrypt := NewBcrypt(4)
+	argon := &Argon2id{Time: 1, Memory: 1024, Threads: 1, SaltLen: 16, KeyLen: 32}
+	passwords := NewPasswords(argon, bcrypt, LegacySHA256{})
+
+	encoded, err := passwords.Hash("secret")
+	assert.NoError(t, err)
+
+	ok, rehash, err := passwords.Verify("secret", encoded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	// legacy hashes are accepted and flagged for an upgrade
+	ok, rehash, err = passwords.Verify("secret", SHA256("secret"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	ok, rehash, err = passwords.Verify("wrong", SHA256("secret"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rehash)
+
+	legacy, _ := bcrypt.Hash("secret")
+	ok, rehash, err = passwords.Verify("secret", legacy)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	_, _, err = passwords.Verify("secret", "plain")
+	assert.Equal(t, ErrUnknownHash, err)
+}
@@ -0,0 +1,147 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrUnsupportedKey   = errors.New("jwk: unsupported key type")
+	ErrUnsupportedCurve = errors.New("jwk: unsupported elliptic curve")
+	ErrInvalidKey       = errors.New("jwk: invalid key parameters")
+)
+
+// Key is a public JSON Web Key (RFC 7517)
+type Key struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// Set is a JSON Web Key Set
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// New creates a signature key from a rsa or ecdsa public key
+func New(kid, alg string, key crypto.PublicKey) (Key, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return Key{
+			Kty: "RSA",
+			Use: "sig",
+			Kid: kid,
+			Alg: alg,
+			N:   encoding.EncodeToString(k.N.Bytes()),
+			E:   encoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return Key{
+			Kty: "EC",
+			Use: "sig",
+			Kid: kid,
+			Alg: alg,
+			Crv: k.Curve.Params().Name,
+			X:   encoding.EncodeToString(pad(k.X.Bytes(), size)),
+			Y:   encoding.EncodeToString(pad(k.Y.Bytes(), size)),
+		}, nil
+	}
+
+	return Key{}, ErrUnsupportedKey
+}
+
+// PublicKey converts the key back into a rsa or ecdsa public key
+func (a Key) PublicKey() (crypto.PublicKey, error) {
+	switch a.Kty {
+	case "RSA":
+		n, err := encoding.DecodeString(a.N)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+
+		e, err := encoding.DecodeString(a.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, ErrInvalidKey
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch a.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedCurve
+		}
+
+		x, err := encoding.DecodeString(a.X)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+
+		y, err := encoding.DecodeString(a.Y)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+
+		key := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, ErrInvalidKey
+		}
+
+		return key, nil
+	}
+
+	return nil, ErrUnsupportedKey
+}
+
+// Find returns the key with the given kid
+func (a Set) Find(kid string) (Key, bool) {
+	for _, key := range a.Keys {
+		if key.Kid == kid {
+			return key, true
+		}
+	}
+
+	return Key{}, false
+}
+
+func pad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
@@ -0,0 +1,58 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRSA(t *testing.T) {
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	key, err := New("k1", "RS256", &pk.PublicKey)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "RSA", key.Kty)
+	assert.EqualValues(t, "AQAB", key.E)
+
+	pub, err := key.PublicKey()
+	assert.Nil(t, err)
+	assert.True(t, pk.PublicKey.Equal(pub))
+}
+
+func TestEC(t *testing.T) {
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	key, err := New("k2", "ES256", &pk.PublicKey)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "P-256", key.Crv)
+	assert.Len(t, key.X, 43)
+
+	pub, err := key.PublicKey()
+	assert.Nil(t, err)
+	assert.True(t, pk.PublicKey.Equal(pub))
+}
+
+func TestSet(t *testing.T) {
+	_, err := New("k3", "HS256", []byte("secret"))
+	assert.Equal(t, ErrUnsupportedKey, err)
+
+	var set Set
+	err = json.Unmarshal([]byte(`{"keys":[{"kty":"EC","kid":"a","crv":"P-256","x":"AA","y":"AA"}]}`), &set)
+	assert.Nil(t, err)
+
+	key, ok := set.Find("a")
+	assert.True(t, ok)
+
+	_, err = key.PublicKey()
+	assert.Equal(t, ErrInvalidKey, err)
+
+	_, ok = set.Find("b")
+	assert.False(t, ok)
+}
@@ -0,0 +1,221 @@
+package ldapx
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("ldap: invalid credentials")
+	ErrUserNotFound       = errors.New("ldap: user not found")
+	ErrUserNotUnique      = errors.New("ldap: user is not unique")
+)
+
+// Config describes how users are looked up and bound in the directory
+type Config struct {
+	// ldap://host:389 or ldaps://host:636
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+
+	// the search account, an anonymous bind is used when it is empty
+	BindDN       string
+	BindPassword string
+
+	BaseDN string
+	// the filter of the user, %s is replaced with the escaped username, e.g. (uid=%s)
+	UserFilter string
+
+	UsernameAttribute string
+	RealnameAttribute string
+	EmailAttribute    string
+	PhoneAttribute    string
+
+	// the groups are read from the attribute of the user, e.g. memberOf,
+	// or searched with the filter, %s is replaced with the escaped dn of the user, e.g. (member=%s)
+	GroupAttribute string
+	GroupBaseDN    string
+	GroupFilter    string
+}
+
+// Entry is the user found in the directory
+type Entry struct {
+	DN       string
+	Username string
+	Realname string
+	Email    string
+	Phone    string
+	// distinguished names of the groups of the user
+	Groups []string
+}
+
+// Client authenticates users with a search and a bind
+type Client struct {
+	config Config
+}
+
+// New creates a new ldap client
+func New(config Config) *Client {
+	if config.UserFilter == "" {
+		config.UserFilter = "(uid=%s)"
+	}
+	if config.UsernameAttribute == "" {
+		config.UsernameAttribute = "uid"
+	}
+	if config.RealnameAttribute == "" {
+		config.RealnameAttribute = "cn"
+	}
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "mail"
+	}
+	if config.PhoneAttribute == "" {
+		config.PhoneAttribute = "telephoneNumber"
+	}
+	if config.GroupBaseDN == "" {
+		config.GroupBaseDN = config.BaseDN
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	return &Client{config: config}
+}
+
+func (a *Client) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: a.config.InsecureSkipVerify}
+	dialer := &net.Dialer{Timeout: a.config.Timeout}
+
+	conn, err := ldap.DialURL(a.config.URL, ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetTimeout(a.config.Timeout)
+	if a.config.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// Authenticate finds the user and binds as the user with the password
+func (a *Client) Authenticate(username, password string) (*Entry, error) {
+	// an empty password is an unauthenticated bind, which most servers accept
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if a.config.BindDN != "" {
+		err = conn.Bind(a.config.BindDN, a.config.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("ldap: search bind: %w", err)
+	}
+
+	entry, err := a.find(conn, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (a *Client) find(conn *ldap.Conn, username string) (*Entry, error) {
+	attributes := []string{
+		a.config.UsernameAttribute,
+		a.config.RealnameAttribute,
+		a.config.EmailAttribute,
+		a.config.PhoneAttribute,
+	}
+
+	if a.config.GroupAttribute != "" {
+		attributes = append(attributes, a.config.GroupAttribute)
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(a.config.UserFilter, ldap.EscapeFilter(username)),
+		attributes, nil,
+	))
+
+	if err != nil {
+		return nil, err
+	} else if len(result.Entries) == 0 {
+		return nil, ErrUserNotFound
+	} else if len(result.Entries) > 1 {
+		return nil, ErrUserNotUnique
+	}
+
+	item := result.Entries[0]
+	entry := &Entry{
+		DN:       item.DN,
+		Username: item.GetAttributeValue(a.config.UsernameAttribute),
+		Realname: item.GetAttributeValue(a.config.RealnameAttribute),
+		Email:    item.GetAttributeValue(a.config.EmailAttribute),
+		Phone:    item.GetAttributeValue(a.config.PhoneAttribute),
+	}
+
+	if entry.Username == "" {
+		entry.Username = username
+	}
+
+	if a.config.GroupAttribute != "" {
+		entry.Groups = item.GetAttributeValues(a.config.GroupAttribute)
+	}
+
+	if a.config.GroupFilter != "" {
+		groups, err := a.groups(conn, entry.DN)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Groups = append(entry.Groups, groups...)
+	}
+
+	return entry, nil
+}
+
+func (a *Client) groups(conn *ldap.Conn, dn string) ([]string, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.config.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf(a.config.GroupFilter, ldap.EscapeFilter(dn)),
+		[]string{"dn"}, nil,
+	))
+
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]string, len(result.Entries))
+	for i, item := range result.Entries {
+		groups[i] = item.DN
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,285 @@
+package ldapx
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+)
+
+type testEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// testServer is an in-process directory that answers simple binds and searches
+type testServer struct {
+	listener net.Listener
+	entries  []*testEntry
+}
+
+func newTestServer(t *testing.T, entries ...*testEntry) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &testServer{listener: listener, entries: entries}
+	go server.serve()
+
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (a *testServer) URL() string {
+	return "ldap://" + a.listener.Addr().String()
+}
+
+func (a *testServer) serve() {
+	for {
+		conn, err := a.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go a.handle(conn)
+	}
+}
+
+func (a *testServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		request, err := ber.ReadPacket(conn)
+		if err != nil || len(request.Children) < 2 {
+			return
+		}
+
+		id := request.Children[0].Value.(int64)
+		op := request.Children[1]
+
+		switch op.Tag {
+		case 0: // bind
+			code := int64(49)
+			name := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+
+			if name == "" && password == "" {
+				code = 0
+			} else if entry := a.find(name); entry != nil && entry.password == password {
+				code = 0
+			}
+
+			conn.Write(response(id, result(1, code)).Bytes())
+		case 2: // unbind
+			return
+		case 3: // search
+			base := strings.ToLower(op.Children[0].Value.(string))
+			for _, entry := range a.entries {
+				if strings.HasSuffix(strings.ToLower(entry.dn), base) && match(entry, op.Children[6]) {
+					conn.Write(response(id, searchEntry(entry)).Bytes())
+				}
+			}
+
+			conn.Write(response(id, result(5, 0)).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func (a *testServer) find(dn string) *testEntry {
+	for _, entry := range a.entries {
+		if strings.EqualFold(entry.dn, dn) {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// match evaluates the and, or, equality and present filters
+func match(entry *testEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case 0:
+		for _, child := range filter.Children {
+			if !match(entry, child) {
+				return false
+			}
+		}
+
+		return true
+	case 1:
+		for _, child := range filter.Children {
+			if match(entry, child) {
+				return true
+			}
+		}
+
+		return false
+	case 3:
+		name, value := filter.Children[0].Data.String(), filter.Children[1].Data.String()
+		for _, v := range entry.values(name) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+
+		return false
+	case 7:
+		return strings.EqualFold(filter.Data.String(), "objectClass") || len(entry.values(filter.Data.String())) > 0
+	}
+
+	return false
+}
+
+func (a *testEntry) values(name string) []string {
+	for k, v := range a.attributes {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func response(id int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	packet.AppendChild(op)
+	return packet
+}
+
+func result(tag ber.Tag, code int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "ResultCode"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "MatchedDN"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "DiagnosticMessage"))
+	return packet
+}
+
+func searchEntry(entry *testEntry) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 4, nil, "Search Result Entry")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "DN"))
+
+	attributes := ber.NewSequence("Attributes")
+	for name, values := range entry.attributes {
+		attribute := ber.NewSequence("Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+
+	packet.AppendChild(attributes)
+	return packet
+}
+
+var (
+	searcher = &testEntry{
+		dn:       "cn=search,dc=example,dc=com",
+		password: "search-secret",
+	}
+
+	alice = &testEntry{
+		dn:       "uid=alice,ou=people,dc=example,dc=com",
+		password: "alice-secret",
+		attributes: map[string][]string{
+			"uid":             {"alice"},
+			"cn":              {"Alice Liddell"},
+			"mail":            {"alice@example.com"},
+			"telephoneNumber": {"10086"},
+			"memberOf":        {"cn=admins,ou=groups,dc=example,dc=com"},
+		},
+	}
+
+	bob = &testEntry{
+		dn:       "uid=bob,ou=people,dc=example,dc=com",
+		password: "bob-secret",
+		attributes: map[string][]string{
+			"uid": {"bob"},
+			"cn":  {"Bob"},
+		},
+	}
+
+	developers = &testEntry{
+		dn: "cn=developers,ou=groups,dc=example,dc=com",
+		attributes: map[string][]string{
+			"cn":     {"developers"},
+			"member": {"uid=alice,ou=people,dc=example,dc=com", "uid=bob,ou=people,dc=example,dc=com"},
+		},
+	}
+)
+
+func TestAuthenticate(t *testing.T) {
+	server := newTestServer(t, searcher, alice, bob, developers)
+	client := New(Config{
+		URL:            server.URL(),
+		BindDN:         searcher.dn,
+		BindPassword:   searcher.password,
+		BaseDN:         "ou=people,dc=example,dc=com",
+		GroupAttribute: "memberOf",
+	})
+
+	entry, err := client.Authenticate("alice", "alice-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, &Entry{
+		DN:       alice.dn,
+		Username: "alice",
+		Realname: "Alice Liddell",
+		Email:    "alice@example.com",
+		Phone:    "10086",
+		Groups:   []string{"cn=admins,ou=groups,dc=example,dc=com"},
+	}, entry)
+
+	_, err = client.Authenticate("alice", "wrong")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	_, err = client.Authenticate("alice", "")
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	_, err = client.Authenticate("carol", "carol-secret")
+	assert.Equal(t, ErrUserNotFound, err)
+
+	// the username is escaped in the filter
+	_, err = client.Authenticate("*", "alice-secret")
+	assert.Equal(t, ErrUserNotFound, err)
+}
+
+func TestAuthenticateGroupFilter(t *testing.T) {
+	server := newTestServer(t, alice, bob, developers)
+	client := New(Config{
+		URL:         server.URL(),
+		BaseDN:      "dc=example,dc=com",
+		UserFilter:  "(&(objectClass=*)(uid=%s))",
+		GroupBaseDN: "ou=groups,dc=example,dc=com",
+		GroupFilter: "(member=%s)",
+	})
+
+	entry, err := client.Authenticate("bob", "bob-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob", entry.Realname)
+	assert.Equal(t, []string{developers.dn}, entry.Groups)
+}
+
+func TestAuthenticateSearchBind(t *testing.T) {
+	server := newTestServer(t, searcher, alice)
+	client := New(Config{
+		URL:          server.URL(),
+		BindDN:       searcher.dn,
+		BindPassword: "wrong",
+		BaseDN:       "dc=example,dc=com",
+	})
+
+	_, err := client.Authenticate("alice", "alice-secret")
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrInvalidCredentials, err)
+}
@@ -0,0 +1,328 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/RealLiuSha/echo-admin/pkg/jwk"
+)
+
+var (
+	ErrInvalidToken  = errors.New("oidc: id token is invalid")
+	ErrExpiredToken  = errors.New("oidc: id token is expired")
+	ErrInvalidIssuer = errors.New("oidc: issuer does not match")
+	ErrInvalidNonce  = errors.New("oidc: nonce does not match")
+	ErrUnknownKey    = errors.New("oidc: signing key is unknown")
+)
+
+// Config of the client registered at the provider
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// default openid, profile and email
+	Scopes     []string
+	HTTPClient *http.Client
+}
+
+// Discovery is the provider metadata of /.well-known/openid-configuration
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// Token is the response of the token endpoint
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// Provider runs the authorization code flow with PKCE against an issuer,
+// the metadata and keys are fetched on first use
+type Provider struct {
+	config Config
+
+	mutex     sync.Mutex
+	discovery *Discovery
+	keys      jwk.Set
+}
+
+// NewProvider creates a new provider
+func NewProvider(config Config) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	return &Provider{config: config}
+}
+
+// Discover returns the provider metadata
+func (a *Provider) Discover() (*Discovery, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.discovery != nil {
+		return a.discovery, nil
+	}
+
+	discovery := new(Discovery)
+	if err := a.get(a.config.Issuer+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != a.config.Issuer {
+		return nil, ErrInvalidIssuer
+	}
+
+	a.discovery = discovery
+	return discovery, nil
+}
+
+// AuthCodeURL is where the user is sent to sign in,
+// the challenge is derived from the verifier with Challenge
+func (a *Provider) AuthCodeURL(state, nonce, challenge string) (string, error) {
+	discovery, err := a.Discover()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {a.config.ClientID},
+		"redirect_uri":          {a.config.RedirectURL},
+		"scope":                 {strings.Join(a.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code with the verifier of its challenge
+func (a *Provider) Exchange(code, verifier string) (*Token, error) {
+	discovery, err := a.Discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {a.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+
+	request, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(a.config.ClientID), url.QueryEscape(a.config.ClientSecret))
+
+	token := new(Token)
+	if err := a.do(request, token); err != nil {
+		return nil, err
+	} else if token.IDToken == "" {
+		return nil, fmt.Errorf("oidc: token response has no id token")
+	}
+
+	return token, nil
+}
+
+// Verify checks the signature, issuer, audience, expiry and nonce of the id token
+func (a *Provider) Verify(rawIDToken, nonce string) (*IDToken, error) {
+	discovery, err := a.Discover()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		default:
+			return nil, ErrInvalidToken
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return a.key(discovery, kid)
+	})
+
+	if err != nil {
+		if e, ok := err.(*jwt.ValidationError); ok {
+			if e.Errors&jwt.ValidationErrorExpired != 0 {
+				return nil, ErrExpiredToken
+			} else if e.Inner == ErrUnknownKey {
+				return nil, ErrUnknownKey
+			}
+		}
+
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	token := &IDToken{Claims: claims}
+	token.Subject = token.String("sub")
+
+	if iss := token.String("iss"); strings.TrimSuffix(iss, "/") != a.config.Issuer {
+		return nil, ErrInvalidIssuer
+	} else if token.Subject == "" || !token.hasAudience(a.config.ClientID) {
+		return nil, ErrInvalidToken
+	} else if _, ok := claims["exp"]; !ok {
+		return nil, ErrInvalidToken
+	} else if token.String("nonce") != nonce {
+		return nil, ErrInvalidNonce
+	}
+
+	return token, nil
+}
+
+// key returns the public key of the kid, the keys are fetched again when the kid is unknown
+func (a *Provider) key(discovery *Discovery, kid string) (interface{}, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	key, ok := a.find(kid)
+	if !ok {
+		keys := jwk.Set{}
+		if err := a.get(discovery.JwksURI, &keys); err != nil {
+			return nil, err
+		}
+
+		a.keys = keys
+		if key, ok = a.find(kid); !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	return key.PublicKey()
+}
+
+// find matches the kid, a token without kid matches the only key of the set
+func (a *Provider) find(kid string) (jwk.Key, bool) {
+	if kid == "" {
+		if len(a.keys.Keys) == 1 {
+			return a.keys.Keys[0], true
+		}
+
+		return jwk.Key{}, false
+	}
+
+	return a.keys.Find(kid)
+}
+
+func (a *Provider) get(endpoint string, out interface{}) error {
+	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	return a.do(request, out)
+}
+
+func (a *Provider) do(request *http.Request, out interface{}) error {
+	response, err := a.config.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s %s returned %s", request.Method, request.URL.Path, response.Status)
+	}
+
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// IDToken is a verified id token
+type IDToken struct {
+	Subject string
+	Claims  map[string]interface{}
+}
+
+// String returns the claim if it is a string
+func (a *IDToken) String(claim string) string {
+	v, _ := a.Claims[claim].(string)
+	return v
+}
+
+// Bool returns the claim if it is a boolean
+func (a *IDToken) Bool(claim string) bool {
+	v, _ := a.Claims[claim].(bool)
+	return v
+}
+
+// Strings returns the claim as a list, a single string is a list of one
+func (a *IDToken) Strings(claim string) []string {
+	switch v := a.Claims[claim].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+
+		return list
+	}
+
+	return nil
+}
+
+func (a *IDToken) hasAudience(clientID string) bool {
+	for _, aud := range a.Strings("aud") {
+		if aud == clientID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewVerifier returns a random PKCE code verifier
+func NewVerifier() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Challenge returns the S256 code challenge of the verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,219 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RealLiuSha/echo-admin/pkg/jwk"
+)
+
+// testIssuer is a local provider that issues id tokens for the codes it handed out
+type testIssuer struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	kid   string
+	mutex sync.Mutex
+	codes map[string]url.Values
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	issuer := &testIssuer{key: key, kid: "k1", codes: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                issuer.URL,
+			AuthorizationEndpoint: issuer.URL + "/authorize",
+			TokenEndpoint:         issuer.URL + "/token",
+			JwksURI:               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		k, _ := jwk.New(issuer.kid, "RS256", &issuer.key.PublicKey)
+		json.NewEncoder(w).Encode(jwk.Set{Keys: []jwk.Key{k}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		code := NewVerifier()
+
+		issuer.mutex.Lock()
+		issuer.codes[code] = r.URL.Query()
+		issuer.mutex.Unlock()
+
+		http.Redirect(w, r, r.URL.Query().Get("redirect_uri")+"?code="+code+"&state="+r.URL.Query().Get("state"), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		r.ParseForm()
+
+		issuer.mutex.Lock()
+		params, ok := issuer.codes[r.PostForm.Get("code")]
+		delete(issuer.codes, r.PostForm.Get("code"))
+		issuer.mutex.Unlock()
+
+		if !ok || clientID != "admin" || secret != "s3cret" ||
+			Challenge(r.PostForm.Get("code_verifier")) != params.Get("code_challenge") {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(Token{
+			AccessToken: "access",
+			TokenType:   "Bearer",
+			IDToken:     issuer.sign(jwt.MapClaims{"nonce": params.Get("nonce")}),
+		})
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (a *testIssuer) sign(claims jwt.MapClaims) string {
+	now := time.Now()
+	all := jwt.MapClaims{
+		"iss":                a.URL,
+		"sub":                "u-1",
+		"aud":                []string{"admin"},
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Minute).Unix(),
+		"preferred_username": "alice",
+		"groups":             []string{"admins", "developers"},
+	}
+
+	for k, v := range claims {
+		all[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, all)
+	token.Header["kid"] = a.kid
+
+	signed, err := token.SignedString(a.key)
+	if err != nil {
+		panic(err)
+	}
+
+	return signed
+}
+
+func newTestProvider(issuer *testIssuer) *Provider {
+	return NewProvider(Config{
+		Issuer:       issuer.URL,
+		ClientID:     "admin",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://127.0.0.1/callback",
+	})
+}
+
+// authorize follows the redirect of the issuer and returns the code
+func authorize(t *testing.T, authURL string) string {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	response, err := client.Get(authURL)
+	assert.Nil(t, err)
+	defer response.Body.Close()
+
+	location, err := url.Parse(response.Header.Get("Location"))
+	assert.Nil(t, err)
+	assert.Equal(t, "state", location.Query().Get("state"))
+
+	return location.Query().Get("code")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := newTestProvider(issuer)
+
+	verifier := NewVerifier()
+	authURL, err := provider.AuthCodeURL("state", "nonce", Challenge(verifier))
+	assert.Nil(t, err)
+
+	u, _ := url.Parse(authURL)
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.Equal(t, "openid profile email", u.Query().Get("scope"))
+
+	token, err := provider.Exchange(authorize(t, authURL), verifier)
+	assert.Nil(t, err)
+
+	idToken, err := provider.Verify(token.IDToken, "nonce")
+	assert.Nil(t, err)
+	assert.Equal(t, "u-1", idToken.Subject)
+	assert.Equal(t, "alice", idToken.String("preferred_username"))
+	assert.Equal(t, []string{"admins", "developers"}, idToken.Strings("groups"))
+
+	_, err = provider.Verify(token.IDToken, "other")
+	assert.Equal(t, ErrInvalidNonce, err)
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := newTestProvider(issuer)
+
+	authURL, err := provider.AuthCodeURL("state", "nonce", Challenge(NewVerifier()))
+	assert.Nil(t, err)
+
+	_, err = provider.Exchange(authorize(t, authURL), NewVerifier())
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := newTestProvider(issuer)
+
+	_, err := provider.Verify(issuer.sign(jwt.MapClaims{"nonce": "n"}), "n")
+	assert.Nil(t, err)
+
+	_, err = provider.Verify(issuer.sign(jwt.MapClaims{"nonce": "n", "aud": "other"}), "n")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = provider.Verify(issuer.sign(jwt.MapClaims{"nonce": "n", "iss": "https://evil.example.com"}), "n")
+	assert.Equal(t, ErrInvalidIssuer, err)
+
+	_, err = provider.Verify(issuer.sign(jwt.MapClaims{"nonce": "n", "exp": time.Now().Add(-time.Minute).Unix()}), "n")
+	assert.Equal(t, ErrExpiredToken, err)
+
+	// tokens signed with a shared secret are refused
+	hs, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": issuer.URL, "sub": "u-1", "aud": "admin", "nonce": "n",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("secret"))
+
+	_, err = provider.Verify(hs, "n")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := newTestProvider(issuer)
+
+	_, err := provider.Verify(issuer.sign(jwt.MapClaims{"nonce": "n"}), "n")
+	assert.Nil(t, err)
+
+	// the keys are fetched again for an unknown kid
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	issuer.key, issuer.kid = key, "k2"
+
+	_, err = provider.Verify(issuer.sign(jwt.MapClaims{"nonce": "n"}), "n")
+	assert.Nil(t, err)
+}
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+	assert.Len(t, NewVerifier(), 43)
+}
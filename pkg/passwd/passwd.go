@@ -0,0 +1,86 @@
+// Package passwd checks passwords against a strength policy
+package passwd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrTooShort     = errors.New("password is too short")
+	ErrMissingClass = errors.New("password is missing character classes")
+	ErrBlocked      = errors.New("password is too common")
+)
+
+// Policy 密码强度策略
+type Policy struct {
+	MinLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+
+	blocklist map[string]struct{}
+}
+
+// Block refuses the words as passwords, case insensitively
+func (a *Policy) Block(words ...string) {
+	if a.blocklist == nil {
+		a.blocklist = make(map[string]struct{}, len(words))
+	}
+
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			a.blocklist[strings.ToLower(word)] = struct{}{}
+		}
+	}
+}
+
+// Validate returns the first rule of the policy the password breaks,
+// the errors wrap ErrTooShort, ErrMissingClass or ErrBlocked
+func (a *Policy) Validate(password string) error {
+	if n := utf8.RuneCountInString(password); n < a.MinLength {
+		return fmt.Errorf("%w, at least %d characters are required", ErrTooShort, a.MinLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	var missing []string
+	if a.RequireUppercase && !upper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if a.RequireLowercase && !lower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if a.RequireDigit && !digit {
+		missing = append(missing, "a digit")
+	}
+	if a.RequireSymbol && !symbol {
+		missing = append(missing, "a symbol")
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w, it must contain %s", ErrMissingClass, strings.Join(missing, ", "))
+	}
+
+	if _, ok := a.blocklist[strings.ToLower(password)]; ok {
+		return ErrBlocked
+	}
+
+	return nil
+}
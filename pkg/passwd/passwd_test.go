@@ -0,0 +1,38 @@
+package passwd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := &Policy{
+		MinLength:        8,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+	}
+	policy.Block("Password1", " Welcome123 ")
+
+	assert.NoError(t, policy.Validate("Tr0ub4dor"))
+
+	err := policy.Validate("Ab1")
+	assert.True(t, errors.Is(err, ErrTooShort))
+	assert.Equal(t, "password is too short, at least 8 characters are required", err.Error())
+
+	err = policy.Validate("abcdefgh")
+	assert.True(t, errors.Is(err, ErrMissingClass))
+	assert.Equal(t, "password is missing character classes, it must contain an uppercase letter, a digit", err.Error())
+
+	assert.Equal(t, ErrBlocked, policy.Validate("PassWord1"))
+	assert.Equal(t, ErrBlocked, policy.Validate("Welcome123"))
+
+	policy.RequireSymbol = true
+	assert.True(t, errors.Is(policy.Validate("Tr0ub4dor"), ErrMissingClass))
+	assert.NoError(t, policy.Validate("Tr0ub4dor&3"))
+
+	// the zero policy accepts anything
+	assert.NoError(t, new(Policy).Validate(""))
+}
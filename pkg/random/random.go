@@ -1,6 +1,8 @@
 package random
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"math/rand"
 	"strings"
 	"time"
@@ -46,3 +48,14 @@ func (*Random) String(length uint8, charsets ...string) string {
 func String(length uint8, charsets ...string) string {
 	return global.String(length, charsets...)
 }
+
+// Token returns a hex encoded string of size cryptographically secure random bytes,
+// suitable for secrets such as refresh or reset tokens
+func Token(size int) string {
+	b := make([]byte, size)
+	if _, err := crand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -12,3 +12,10 @@ func Test(t *testing.T) {
 	r := New()
 	assert.Regexp(t, regexp.MustCompile("[0-9]+$"), r.String(8, Numeric))
 }
+
+func TestToken(t *testing.T) {
+	token := Token(32)
+	assert.Len(t, token, 64)
+	assert.Regexp(t, regexp.MustCompile("^[0-9a-f]+$"), token)
+	assert.NotEqual(t, token, Token(32))
+}
@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+
+	// test vectors of RFC 6238 appendix B, SHA1 mode
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for unix, code := range vectors {
+		assert.Equal(t, code, generate(key, Counter(time.Unix(unix, 0)), 8))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	now := time.Unix(1600000000, 0)
+	code, err := Code(secret, now)
+	assert.NoError(t, err)
+	assert.Len(t, code, Digits)
+
+	counter, ok := Validate(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, Counter(now), counter)
+
+	// the previous period is still accepted
+	counter, ok = Validate(secret, code, now.Add(Period*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, Counter(now), counter)
+
+	_, ok = Validate(secret, code, now.Add(2*Period*time.Second))
+	assert.False(t, ok)
+
+	_, ok = Validate(secret, "12345", now)
+	assert.False(t, ok)
+
+	_, ok = Validate("not base32!", code, now)
+	assert.False(t, ok)
+
+	_, err = Code("", now)
+	assert.Equal(t, ErrInvalidSecret, err)
+}
+
+func TestURI(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	u, err := url.Parse(URI(secret, "echo admin", "alice@example.com"))
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/echo admin:alice@example.com", u.Path)
+	assert.Equal(t, secret, u.Query().Get("secret"))
+	assert.Equal(t, "echo admin", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+}